@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// maxBatchSize is the most sub-requests a single batch may contain
+const maxBatchSize = 50
+
+// batchRequest is one operation inside a batch. ContentType is the
+// operation's own body type, such as application/merge-patch+json, and
+// defaults to application/json.
+type batchRequest struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	ContentType string          `json:"content_type,omitempty"`
+	Body        json.RawMessage `json:"body,omitempty"`
+}
+
+// batchInput is the request body for POST /v1/batch
+type batchInput struct {
+	Transactional bool           `json:"transactional"`
+	Requests      []batchRequest `json:"requests"`
+}
+
+// batchResult is the outcome of one operation inside a batch
+type batchResult struct {
+	Status   int             `json:"status"`
+	Location string          `json:"location,omitempty"`
+	Body     json.RawMessage `json:"body,omitempty"`
+}
+
+// batchRecorder captures a sub-request's response in memory
+type batchRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBatchRecorder() *batchRecorder {
+	return &batchRecorder{header: make(http.Header)}
+}
+
+func (rec *batchRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *batchRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *batchRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+// Flush is a no-op so streaming handlers work inside a batch
+func (rec *batchRecorder) Flush() {}
+
+// result converts the recorded response into a batchResult. JSON bodies
+// are embedded as-is and anything else is embedded as a JSON string.
+func (rec *batchRecorder) result() batchResult {
+	res := batchResult{
+		Status:   rec.status,
+		Location: rec.header.Get("Location"),
+	}
+	if res.Status == 0 {
+		res.Status = http.StatusOK
+	}
+
+	body := bytes.TrimSpace(rec.body.Bytes())
+	if len(body) == 0 {
+		return res
+	}
+	if json.Valid(body) {
+		res.Body = body
+		return res
+	}
+	js, _ := json.Marshal(string(body))
+	res.Body = js
+	return res
+}
+
+// validateBatchRequest checks a single operation before it is dispatched
+func validateBatchRequest(v *validator.Validator, i int, req batchRequest) {
+	key := fmt.Sprintf("requests[%d]", i)
+
+	switch req.Method {
+	case http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodPut, http.MethodDelete:
+	default:
+		v.AddError(key+".method", "must be one of GET, POST, PATCH, PUT or DELETE")
+	}
+
+	v.Check(strings.HasPrefix(req.Path, "/v1/"), key+".path", "must start with /v1/")
+	u, err := url.Parse(req.Path)
+	v.Check(err == nil, key+".path", "must be a valid path")
+	if err == nil {
+		v.Check(u.Path != "/v1/batch", key+".path", "must not be a nested batch")
+		v.Check(!strings.HasPrefix(u.Path, "/v1/stream/"), key+".path", "must not be an event stream")
+	}
+
+	if req.ContentType != "" {
+		_, _, err := mime.ParseMediaType(req.ContentType)
+		v.Check(err == nil, key+".content_type", "must be a valid media type")
+	}
+}
+
+// batchHandler returns a handler that runs an ordered list of sub-requests
+// through router. With "transactional" set, the sub-requests share a single
+// database transaction that is rolled back if any of them fails.
+func (a *applicationDependencies) batchHandler(router http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input batchInput
+
+		err := a.readJSON(w, r, &input)
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+
+		v := validator.New()
+		v.Check(len(input.Requests) > 0, "requests", "must contain at least one request")
+		v.Check(len(input.Requests) <= maxBatchSize, "requests", fmt.Sprintf("must not contain more than %d requests", maxBatchSize))
+		for i, req := range input.Requests {
+			validateBatchRequest(v, i, req)
+		}
+		if !v.IsEmpty() {
+			a.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		if !input.Transactional {
+			results := make([]batchResult, len(input.Requests))
+			for i, req := range input.Requests {
+				results[i] = a.dispatchBatchRequest(router, r, req)
+			}
+
+			err = a.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		tx, err := a.db.BeginTx(r.Context(), nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		defer tx.Rollback()
+
+		// a copy of the application whose models all run inside tx
+		txApp := a.bindTx(tx)
+		txRouter := txApp.router()
+
+		results := make([]batchResult, len(input.Requests))
+		failed := -1
+		for i, req := range input.Requests {
+			if failed >= 0 {
+				results[i] = batchResult{Status: http.StatusFailedDependency}
+				continue
+			}
+			results[i] = txApp.dispatchBatchRequest(txRouter, r, req)
+			if results[i].Status >= 400 {
+				failed = i
+			}
+		}
+
+		committed := false
+		if failed < 0 {
+			err = tx.Commit()
+			if err != nil {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+			committed = true
+			// streams and webhooks were woken before the events were visible
+			a.committed()
+		}
+
+		err = a.writeJSON(w, http.StatusOK, envelope{"committed": committed, "results": results}, nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+		}
+	}
+}
+
+// dispatchBatchRequest builds a sub-request that inherits the parent's
+// context, client address and headers, and serves it through router.
+func (a *applicationDependencies) dispatchBatchRequest(router http.Handler, parent *http.Request, req batchRequest) batchResult {
+	sub, err := http.NewRequestWithContext(parent.Context(), req.Method, req.Path, bytes.NewReader(req.Body))
+	if err != nil {
+		return batchResult{Status: http.StatusBadRequest}
+	}
+
+	for key, values := range parent.Header {
+		// each sub-request is its own operation, so the batch's key must not leak into it
+		if key == "Content-Length" || key == "Idempotency-Key" {
+			continue
+		}
+		sub.Header[key] = values
+	}
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	sub.Header.Set("Content-Type", contentType)
+	sub.RemoteAddr = parent.RemoteAddr
+
+	rec := newBatchRecorder()
+	router.ServeHTTP(rec, sub)
+	return rec.result()
+}
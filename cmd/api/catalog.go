@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// The functions in this file are the product and review writes shared by
+// the HTTP handlers and the gRPC servers. Each transport reads its request,
+// checks the caller may make the call, and reports the returned error in
+// its own way.
+
+// validationError carries the field errors of a write that failed
+// validation or screening
+type validationError struct {
+	errors map[string]string
+}
+
+func (e *validationError) Error() string {
+	return "one or more fields failed validation"
+}
+
+// visibleProduct loads a product for user. Drafts are only visible to
+// editors; everyone else is told the product doesn't exist.
+func (a *applicationDependencies) visibleProduct(user *data.User, id int64) (*data.Product, error) {
+	product, err := a.productModel.Get(id)
+	if err != nil || product.Status != data.ProductDraft {
+		return product, err
+	}
+
+	editor, err := a.userHasPermission(user, "products:edit")
+	if err != nil {
+		return nil, err
+	}
+	if !editor {
+		return nil, errors.New("product not found")
+	}
+	return product, nil
+}
+
+// productStatusVisible reports whether user may see products in status
+func (a *applicationDependencies) productStatusVisible(user *data.User, status string) (bool, error) {
+	if status != data.ProductDraft {
+		return true, nil
+	}
+	return a.userHasPermission(user, "products:edit")
+}
+
+// createProduct validates and saves a new product
+func (a *applicationDependencies) createProduct(ctx context.Context, product *data.Product) error {
+	v := validator.New()
+	data.ValidateProduct(v, product)
+	if !v.IsEmpty() {
+		return &validationError{errors: v.Errors}
+	}
+
+	return a.atomically(ctx, func(tx *applicationDependencies) error {
+		err := tx.productModel.Insert(product)
+		if err != nil {
+			return err
+		}
+		return tx.publish(data.EventProductCreated, product)
+	})
+}
+
+// updateProduct validates and saves user's changes to a product, recording
+// previous as its last revision
+func (a *applicationDependencies) updateProduct(ctx context.Context, user *data.User, previous *data.Product, product *data.Product) error {
+	v := validator.New()
+	data.ValidateProduct(v, product)
+	if !v.IsEmpty() {
+		return &validationError{errors: v.Errors}
+	}
+
+	return a.atomically(ctx, func(tx *applicationDependencies) error {
+		err := tx.productModel.Update(product)
+		if err != nil {
+			return err
+		}
+		err = tx.revisionModel.Record(data.RevisionProduct, product.ID, user.ID, previous, product)
+		if err != nil {
+			return err
+		}
+		return tx.publish(data.EventProductUpdated, product)
+	})
+}
+
+// deleteProduct soft-deletes a product
+func (a *applicationDependencies) deleteProduct(ctx context.Context, id int64) error {
+	return a.atomically(ctx, func(tx *applicationDependencies) error {
+		err := tx.productModel.Delete(id)
+		if err != nil {
+			return err
+		}
+		return tx.publish(data.EventProductDeleted, envelope{"id": id})
+	})
+}
+
+// createReview validates, screens and saves a new review of product
+func (a *applicationDependencies) createReview(ctx context.Context, product *data.Product, review *data.Review) error {
+	v := validator.New()
+	data.ValidateReview(v, review)
+	v.Check(product.Status != data.ProductArchived, "product", "is archived and no longer accepts reviews")
+	err := a.checkReviewVariant(v, review)
+	if err != nil {
+		return err
+	}
+	if !v.IsEmpty() {
+		return &validationError{errors: v.Errors}
+	}
+
+	errs, err := a.screenReview(review, "")
+	if err != nil {
+		return err
+	}
+	if errs != nil {
+		return &validationError{errors: errs}
+	}
+
+	return a.atomically(ctx, func(tx *applicationDependencies) error {
+		err := tx.reviewModel.Insert(review)
+		if err != nil {
+			return err
+		}
+		// Record the new review and update the product's average rating
+		return tx.reviewChanged(data.EventReviewCreated, review)
+	})
+}
+
+// updateReview validates and saves user's changes to a review, recording
+// previous as its last revision. An edited review is screened again before
+// it goes back on show.
+func (a *applicationDependencies) updateReview(ctx context.Context, user *data.User, previous *data.Review, review *data.Review) error {
+	v := validator.New()
+	data.ValidateReview(v, review)
+	err := a.checkReviewVariant(v, review)
+	if err != nil {
+		return err
+	}
+	if !v.IsEmpty() {
+		return &validationError{errors: v.Errors}
+	}
+
+	if review.Content != previous.Content || review.Author != previous.Author || review.Rating != previous.Rating {
+		errs, err := a.screenReview(review, previous.Status)
+		if err != nil {
+			return err
+		}
+		if errs != nil {
+			return &validationError{errors: errs}
+		}
+	}
+
+	return a.atomically(ctx, func(tx *applicationDependencies) error {
+		err := tx.reviewModel.Update(review)
+		if err != nil {
+			return err
+		}
+		err = tx.revisionModel.Record(data.RevisionReview, review.ID, user.ID, previous, review)
+		if err != nil {
+			return err
+		}
+		// Record the change and update the product's average rating
+		return tx.reviewChanged(data.EventReviewUpdated, review)
+	})
+}
+
+// deleteReview soft-deletes a review
+func (a *applicationDependencies) deleteReview(ctx context.Context, review *data.Review) error {
+	return a.atomically(ctx, func(tx *applicationDependencies) error {
+		err := tx.reviewModel.Delete(review.ID)
+		if err != nil {
+			return err
+		}
+		// Record the deletion and update the product's average rating
+		return tx.reviewChanged(data.EventReviewDeleted, review)
+	})
+}
+
+// markHelpful records user's helpful vote on a review. A repeated vote
+// changes nothing, so it is neither counted nor published again.
+func (a *applicationDependencies) markHelpful(ctx context.Context, user *data.User, review *data.Review) error {
+	return a.atomically(ctx, func(tx *applicationDependencies) error {
+		counted, err := tx.reviewModel.MarkHelpful(review, user.ID)
+		if err != nil || !counted {
+			return err
+		}
+		return tx.reviewChanged(data.EventReviewUpdated, review)
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+type contextKey string
+
+const userContextKey = contextKey("user")
+
+// contextSetUser returns a copy of r carrying user
+func (a *applicationDependencies) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	return r.WithContext(contextWithUser(r.Context(), user))
+}
+
+// contextGetUser returns the user set by the authenticate middleware
+func (a *applicationDependencies) contextGetUser(r *http.Request) *data.User {
+	return userFromContext(r.Context())
+}
+
+// contextWithUser returns a copy of ctx carrying user. The gRPC server
+// uses it and userFromContext directly since it has no *http.Request.
+func contextWithUser(ctx context.Context, user *data.User) context.Context {
+	return context.WithValue(ctx, userContextKey, user)
+}
+
+func userFromContext(ctx context.Context) *data.User {
+	user, ok := ctx.Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"sync"
+)
+
+// loader batches lookups made while resolving one GraphQL request. Keys
+// requested during a pass over the result tree are queued, and the first
+// thunk to be resolved fetches all queued keys with a single call to fetch.
+// Results are cached for the rest of the request.
+type loader[K comparable, V any] struct {
+	mu      sync.Mutex
+	fetch   func(keys []K) (map[K]V, error)
+	pending []K
+	queued  map[K]bool
+	cache   map[K]V
+	errs    map[K]error
+}
+
+func newLoader[K comparable, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		fetch:  fetch,
+		queued: make(map[K]bool),
+		cache:  make(map[K]V),
+		errs:   make(map[K]error),
+	}
+}
+
+// load queues key and returns a thunk in the shape graphql-go resolves lazily
+func (l *loader[K, V]) load(key K) func() (interface{}, error) {
+	l.mu.Lock()
+	_, cached := l.cache[key]
+	if !cached && !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if len(l.pending) > 0 {
+			keys := l.pending
+			l.pending = nil
+
+			results, err := l.fetch(keys)
+			for _, k := range keys {
+				delete(l.queued, k)
+				if err != nil {
+					l.errs[k] = err
+					continue
+				}
+				l.cache[k] = results[k]
+			}
+		}
+
+		if err := l.errs[key]; err != nil {
+			return nil, err
+		}
+		return l.cache[key], nil
+	}
+}
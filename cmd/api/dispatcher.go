@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+const (
+	// webhookBatchSize is how many outbox events or deliveries are taken at a time
+	webhookBatchSize = 50
+	// webhookPollInterval is how often the dispatcher looks for due retries
+	// and events committed by other instances when nothing has woken it
+	webhookPollInterval = 5 * time.Second
+	// webhookTimeout bounds a single delivery attempt
+	webhookTimeout = 10 * time.Second
+	// webhookLease is how long a claimed delivery is hidden from other
+	// dispatchers; it must be longer than webhookTimeout
+	webhookLease = time.Minute
+	// webhookMaxAttempts is how many failed attempts move a delivery to the dead-letter view
+	webhookMaxAttempts = 8
+	// webhookMinBackoff and webhookMaxBackoff bound the exponential delay between attempts
+	webhookMinBackoff = 30 * time.Second
+	webhookMaxBackoff = 6 * time.Hour
+)
+
+// webhookEvent is the JSON body POSTed to a webhook
+type webhookEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// webhookDispatcher moves events from the outbox to webhook deliveries and
+// sends them, retrying failures with exponential backoff.
+type webhookDispatcher struct {
+	app    *applicationDependencies
+	client *http.Client
+
+	// ctx is cancelled to abandon in-flight deliveries when a drain runs out of time
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	wakeCh chan struct{}
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+func newWebhookDispatcher(a *applicationDependencies) *webhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &webhookDispatcher{
+		app:    a,
+		client: &http.Client{Timeout: webhookTimeout, Transport: webhookTransport()},
+		ctx:    ctx,
+		cancel: cancel,
+		wakeCh: make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// errInternalAddress is returned when a webhook's host resolves to an
+// address the dispatcher won't send to
+var errInternalAddress = errors.New("webhook host resolves to a loopback, link-local or private address")
+
+// webhookTransport dials only public addresses. The check runs on the
+// address actually dialled, after DNS resolution and on every redirect,
+// so a hostname can't be pointed at the internal network after the
+// webhook was validated.
+func webhookTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !data.PublicAddr(addrPort.Addr()) {
+				return errInternalAddress
+			}
+			return nil
+		},
+	}
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+// wake asks the dispatcher to run now rather than at its next poll
+func (d *webhookDispatcher) wake() {
+	select {
+	case d.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// run dispatches until stop is called, then makes a final pass so events
+// committed by the last requests are delivered before the process exits.
+func (d *webhookDispatcher) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.dispatch()
+
+		select {
+		case <-d.stopCh:
+			d.dispatch()
+			return
+		case <-d.wakeCh:
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop drains the dispatcher. If ctx expires first, in-flight deliveries
+// are abandoned; their leases expire and they are retried on the next start.
+func (d *webhookDispatcher) stop(ctx context.Context) error {
+	close(d.stopCh)
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		d.cancel()
+		<-d.done
+		return ctx.Err()
+	}
+}
+
+// dispatch fans out new outbox events, then sends every delivery that is due
+func (d *webhookDispatcher) dispatch() {
+	for d.ctx.Err() == nil {
+		n, err := d.app.outboxModel.FanOut(webhookBatchSize)
+		if err != nil {
+			d.app.logger.Error(err.Error())
+			return
+		}
+		if n < webhookBatchSize {
+			break
+		}
+	}
+
+	for d.ctx.Err() == nil {
+		deliveries, err := d.app.webhookModel.ClaimDue(webhookBatchSize, webhookLease)
+		if err != nil {
+			d.app.logger.Error(err.Error())
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func(delivery *data.PendingDelivery) {
+				defer wg.Done()
+				d.deliver(delivery)
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// deliver makes one attempt and records the outcome
+func (d *webhookDispatcher) deliver(delivery *data.PendingDelivery) {
+	statusCode, err := d.send(delivery)
+	if d.ctx.Err() != nil {
+		// abandoned during shutdown; the lease brings it back later
+		return
+	}
+
+	if err == nil {
+		err = d.app.webhookModel.MarkDelivered(delivery.ID, statusCode)
+		if err != nil {
+			d.app.logger.Error(err.Error())
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	var retryAt *time.Time
+	if attempts < webhookMaxAttempts {
+		next := time.Now().Add(webhookBackoff(attempts))
+		retryAt = &next
+	}
+	d.app.logger.Warn("webhook delivery failed", "delivery_id", delivery.ID, "webhook_id", delivery.WebhookID,
+		"attempts", attempts, "dead", retryAt == nil, "error", err.Error())
+
+	err = d.app.webhookModel.MarkFailed(delivery.ID, statusCode, err.Error(), retryAt)
+	if err != nil {
+		d.app.logger.Error(err.Error())
+	}
+}
+
+// send POSTs the signed event. A non-2xx response is an error.
+func (d *webhookDispatcher) send(delivery *data.PendingDelivery) (int, error) {
+	body, err := json.Marshal(webhookEvent{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.EventCreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(d.ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AWT_Test1-Webhooks/"+appVersion)
+	req.Header.Set("X-Webhook-Id", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "v1="+signWebhook(delivery.Secret, timestamp, body))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// signWebhook computes the hex HMAC-SHA256 of "timestamp.body". Receivers
+// recompute it with their secret and should reject stale timestamps.
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the delay before the attempt after the given number of failures
+func webhookBackoff(attempts int) time.Duration {
+	wait := webhookMinBackoff << (attempts - 1)
+	if wait <= 0 || wait > webhookMaxBackoff {
+		wait = webhookMaxBackoff
+	}
+	return wait
+}
@@ -1,10 +1,60 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/patch"
+)
+
+// Stable, machine-readable error codes returned in the "code" member
+// of every problem response. Clients should branch on these rather
+// than on the human-readable detail.
+const (
+	codeServerError        = "server_error"
+	codeNotFound           = "not_found"
+	codeMethodNotAllowed   = "method_not_allowed"
+	codeBadRequest         = "bad_request"
+	codeFailedValidation   = "failed_validation"
+	codeRateLimitExceeded  = "rate_limit_exceeded"
+	codeNotAcceptable      = "not_acceptable"
+	codeUnsupportedMedia   = "unsupported_media_type"
+	codeIdempotencyReused  = "idempotency_key_reused"
+	codeIdempotencyBusy    = "idempotency_key_in_use"
+	codePatchTestFailed    = "patch_test_failed"
+	codeInvalidCredentials = "invalid_credentials"
+	codeInvalidToken       = "invalid_authentication_token"
+	codeAuthRequired       = "authentication_required"
+	codeNotPermitted       = "not_permitted"
+	codeDuplicateReport    = "duplicate_report"
+	codeDuplicateReview    = "duplicate_review"
+	codeDuplicateResponse  = "duplicate_response"
+	codeDuplicateSKU       = "duplicate_sku"
 )
 
+// problem is an RFC 7807 problem details object
+type problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	Code          string         `json:"code"`
+	InvalidParams []invalidParam `json:"invalid_params,omitempty"`
+}
+
+// invalidParam describes a single field that failed validation
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
 // Log and handle generic errors
 func (a *applicationDependencies) logError(r *http.Request, err error) {
 	method := r.Method
@@ -12,10 +62,56 @@ func (a *applicationDependencies) logError(r *http.Request, err error) {
 	a.logger.Error(err.Error(), "method", method, "uri", uri)
 }
 
-// Send a JSON error response
-func (a *applicationDependencies) errorResponseJSON(w http.ResponseWriter, r *http.Request, status int, message any) {
-	errorData := envelope{"error": message}
-	err := a.writeJSON(w, status, errorData, nil)
+// wantsLegacyErrors reports whether the client asked for the old
+// {"error": ...} shape. Clients that explicitly accept application/json
+// but not application/problem+json keep getting the legacy body.
+func wantsLegacyErrors(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "application/problem+json") {
+		return false
+	}
+	return strings.Contains(accept, "application/json")
+}
+
+// Send an error response as application/problem+json, or in the legacy
+// envelope shape if the client negotiated for it
+func (a *applicationDependencies) errorResponseJSON(w http.ResponseWriter, r *http.Request, status int, code string, message any) {
+	if wantsLegacyErrors(r) {
+		err := a.writeJSON(w, status, envelope{"error": message}, nil)
+		if err != nil {
+			a.logError(r, err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	p := problem{
+		Type:     "/problems/" + strings.ReplaceAll(code, "_", "-"),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: r.URL.RequestURI(),
+		Code:     code,
+	}
+
+	switch m := message.(type) {
+	case string:
+		p.Detail = m
+	case map[string]string:
+		p.Detail = "one or more fields failed validation"
+		for name, reason := range m {
+			p.InvalidParams = append(p.InvalidParams, invalidParam{Name: name, Reason: reason})
+		}
+		// map iteration order is random, keep the output stable
+		sort.Slice(p.InvalidParams, func(i, j int) bool {
+			return p.InvalidParams[i].Name < p.InvalidParams[j].Name
+		})
+	default:
+		p.Detail = fmt.Sprint(m)
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/problem+json")
+	err := a.writeJSON(w, status, p, headers)
 	if err != nil {
 		a.logError(r, err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -26,7 +122,7 @@ func (a *applicationDependencies) errorResponseJSON(w http.ResponseWriter, r *ht
 func (a *applicationDependencies) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	a.logError(r, err)
 	message := "the server encountered a problem and could not process your request"
-	a.errorResponseJSON(w, r, http.StatusInternalServerError, message)
+	a.errorResponseJSON(w, r, http.StatusInternalServerError, codeServerError, message)
 }
 
 // Send a 404 Not Found response with a custom message
@@ -34,26 +130,129 @@ func (a *applicationDependencies) notFoundResponse(w http.ResponseWriter, r *htt
 	if message == "" {
 		message = "the requested resource could not be found"
 	}
-	a.errorResponseJSON(w, r, http.StatusNotFound, message)
+	a.errorResponseJSON(w, r, http.StatusNotFound, codeNotFound, message)
 }
 
 // Send a 405 Method Not Allowed response
 func (a *applicationDependencies) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	a.errorResponseJSON(w, r, http.StatusMethodNotAllowed, message)
+	a.errorResponseJSON(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, message)
 }
 
 // Send a 400 Bad Request response with a custom error message
 func (a *applicationDependencies) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	a.errorResponseJSON(w, r, http.StatusBadRequest, err.Error())
+	a.errorResponseJSON(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
 }
 
 // Send a 422 Unprocessable Entity response for validation errors
 func (a *applicationDependencies) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	a.errorResponseJSON(w, r, http.StatusUnprocessableEntity, errors)
+	a.errorResponseJSON(w, r, http.StatusUnprocessableEntity, codeFailedValidation, errors)
 }
 
 func (a *applicationDependencies) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	// tell clients how long until the limiter has a token for them again
+	retryAfter := 1
+	if a.config.limiter.rps > 0 {
+		retryAfter = int(math.Ceil(1 / a.config.limiter.rps))
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
 	message := "rate limit exceeded"
-	a.errorResponseJSON(w, r, http.StatusTooManyRequests, message)
+	a.errorResponseJSON(w, r, http.StatusTooManyRequests, codeRateLimitExceeded, message)
+}
+
+// Send a 406 Not Acceptable response when no supported format matches the Accept header
+func (a *applicationDependencies) notAcceptableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the requested representation is not supported; use application/json, text/csv, application/x-ndjson or application/xml"
+	a.errorResponseJSON(w, r, http.StatusNotAcceptable, codeNotAcceptable, message)
+}
+
+// Send a 415 Unsupported Media Type response listing the accepted request types
+func (a *applicationDependencies) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request, accepted string) {
+	message := fmt.Sprintf("the request body must be one of: %s", accepted)
+	a.errorResponseJSON(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMedia, message)
+}
+
+// Send a 422 Unprocessable Entity response when an Idempotency-Key is reused for a different request
+func (a *applicationDependencies) idempotencyMismatchResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this Idempotency-Key was already used for a different request"
+	a.errorResponseJSON(w, r, http.StatusUnprocessableEntity, codeIdempotencyReused, message)
+}
+
+// Send a 409 Conflict response while the original request for an Idempotency-Key is still running
+func (a *applicationDependencies) idempotencyInProgressResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a request with this Idempotency-Key is still being processed, please retry later"
+	a.errorResponseJSON(w, r, http.StatusConflict, codeIdempotencyBusy, message)
+}
+
+// Send the response for a patch that could not be applied. A failed JSON
+// Patch "test" operation is a 409 Conflict; anything else is a bad request.
+func (a *applicationDependencies) patchErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, patch.ErrTestFailed) {
+		a.errorResponseJSON(w, r, http.StatusConflict, codePatchTestFailed, err.Error())
+		return
+	}
+	a.badRequestResponse(w, r, err)
+}
+
+// Send a 401 Unauthorized response for a wrong email or password
+func (a *applicationDependencies) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, codeInvalidCredentials, message)
+}
+
+// Send a 401 Unauthorized response for a missing, malformed or expired bearer token
+func (a *applicationDependencies) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	message := "invalid or missing authentication token"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, codeInvalidToken, message)
+}
+
+// Send a 401 Unauthorized response when an anonymous request reaches a protected route
+func (a *applicationDependencies) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you must be authenticated to access this resource"
+	a.errorResponseJSON(w, r, http.StatusUnauthorized, codeAuthRequired, message)
+}
+
+// Send a 403 Forbidden response when the user lacks a permission
+func (a *applicationDependencies) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	a.errorResponseJSON(w, r, http.StatusForbidden, codeNotPermitted, message)
+}
+
+// Send a 409 Conflict response when the user has already reported a review
+func (a *applicationDependencies) duplicateReportResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you have already reported this review"
+	a.errorResponseJSON(w, r, http.StatusConflict, codeDuplicateReport, message)
+}
+
+// Send a 409 Conflict response when the author has already reviewed the product
+func (a *applicationDependencies) duplicateReviewResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this author has already reviewed this product; update the existing review instead"
+	a.errorResponseJSON(w, r, http.StatusConflict, codeDuplicateReview, message)
+}
+
+// Send a 409 Conflict response when a review already has an official response
+func (a *applicationDependencies) duplicateResponseResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this review already has a response; update it instead"
+	a.errorResponseJSON(w, r, http.StatusConflict, codeDuplicateResponse, message)
+}
+
+// Send a 409 Conflict response when another variant already has the SKU
+func (a *applicationDependencies) duplicateSKUResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a variant with this sku already exists"
+	a.errorResponseJSON(w, r, http.StatusConflict, codeDuplicateSKU, message)
+}
+
+// Send the response for an error from one of the shared catalog writes
+func (a *applicationDependencies) catalogErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var failed *validationError
+	switch {
+	case errors.As(err, &failed):
+		a.failedValidationResponse(w, r, failed.errors)
+	case errors.Is(err, data.ErrDuplicateReview):
+		a.duplicateReviewResponse(w, r)
+	default:
+		a.serverErrorResponse(w, r, err)
+	}
 }
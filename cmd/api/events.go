@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+const (
+	// streamBatchSize is how many events are read from the log at a time
+	streamBatchSize = 100
+	// streamHeartbeat is how often an idle stream sends a comment and
+	// re-reads the log, which also picks up events written by other instances
+	streamHeartbeat = 15 * time.Second
+	// streamWriteTimeout replaces the server-wide WriteTimeout for each
+	// write to a stream, so a stalled client is still dropped
+	streamWriteTimeout = 10 * time.Second
+	// streamRetry is the reconnect delay suggested to EventSource clients
+	streamRetry = 3 * time.Second
+)
+
+// eventHub wakes open streams when new review events have been written.
+// It carries no data; each stream reads what it needs from the event log.
+type eventHub struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[chan struct{}]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+func (h *eventHub) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan struct{}) {
+	h.mu.Lock()
+	delete(h.subscribers, ch)
+	h.mu.Unlock()
+}
+
+// notify wakes every subscriber without blocking on slow ones
+func (h *eventHub) notify() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close ends every open stream so the server can shut down
+func (h *eventHub) close() {
+	h.closeOnce.Do(func() {
+		close(h.done)
+	})
+}
+
+// reviewEventRef is the payload of an event about a review that isn't
+// public. The stream and webhooks only ever see approved reviews in full.
+type reviewEventRef struct {
+	ID        int64 `json:"id"`
+	ProductID int64 `json:"product_id"`
+}
+
+// reviewEventPayload is what an event about review carries: the review
+// itself if it is approved, otherwise only its IDs
+func reviewEventPayload(review *data.Review) any {
+	if review.Status == data.ReviewApproved {
+		return review
+	}
+	return reviewEventRef{ID: review.ID, ProductID: review.ProductID}
+}
+
+// reviewChanged records an event for a created, updated or deleted review,
+// recalculates the product's average rating and records a rating.changed
+// event if the average moved. Each event goes to both the stream log and
+// the webhook outbox, so call it inside atomically.
+func (a *applicationDependencies) reviewChanged(eventType string, review *data.Review) error {
+	payload := reviewEventPayload(review)
+	_, err := a.reviewEventModel.Insert(eventType, review.ProductID, review.ID, payload)
+	if err != nil {
+		return err
+	}
+	err = a.publish(eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	change, err := a.productModel.UpdateAverageRating(review.ProductID)
+	if err != nil {
+		return err
+	}
+	if change != nil && change.Previous != change.AverageRating {
+		_, err = a.reviewEventModel.Insert(data.EventRatingChanged, review.ProductID, 0, change)
+		if err != nil {
+			return err
+		}
+		err = a.publish(data.EventRatingChanged, change)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// streamReviewsHandler sends review activity as Server-Sent Events. A
+// reconnecting client resumes after the ID in its Last-Event-ID header (or
+// ?last_event_id=); a new client only sees events from now on.
+func (a *applicationDependencies) streamReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+	v := validator.New()
+
+	var productID int64
+	if s := qs.Get("product_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		v.Check(err == nil && id > 0, "product_id", "must be a positive integer")
+		productID = id
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = qs.Get("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		v.Check(err == nil && id >= 0, "last_event_id", "must be a non-negative integer")
+		lastID = id
+	}
+
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if lastEventID == "" {
+		var err error
+		lastID, err = a.reviewEventModel.LastID()
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	sub := a.reviewEvents.subscribe()
+	defer a.reviewEvents.unsubscribe(sub)
+
+	rc := http.NewResponseController(w)
+
+	// send writes one chunk under its own deadline; the server's
+	// WriteTimeout would otherwise cut the stream off after ten seconds
+	send := func(chunk string) error {
+		err := rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(w, chunk)
+		if err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	err := send(fmt.Sprintf("retry: %d\n\n", streamRetry.Milliseconds()))
+	if err != nil {
+		a.logError(r, err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		events, err := a.reviewEventModel.After(lastID, productID, streamBatchSize)
+		if err != nil {
+			a.logError(r, err)
+			return
+		}
+
+		for _, event := range events {
+			js, err := json.Marshal(event)
+			if err != nil {
+				a.logError(r, err)
+				return
+			}
+			err = send(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, js))
+			if err != nil {
+				return
+			}
+			lastID = event.ID
+		}
+		if len(events) == streamBatchSize {
+			continue
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.reviewEvents.done:
+			return
+		case <-sub:
+		case <-heartbeat.C:
+			err = send(": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// cleanupReviewEvents periodically deletes events older than the retention period
+func (a *applicationDependencies) cleanupReviewEvents() {
+	for {
+		time.Sleep(time.Hour)
+		err := a.reviewEventModel.DeleteBefore(time.Now().Add(-a.config.events.retention))
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	}
+}
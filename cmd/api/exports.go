@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// flushEvery controls how many rows are written between flushes to the client
+const flushEvery = 500
+
+// exportFormat picks NDJSON or CSV for an export. An explicit ?format=
+// wins over the Accept header, and plain JSON negotiates to NDJSON.
+func exportFormat(r *http.Request) (string, bool) {
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		return formatCSV, true
+	case "ndjson":
+		return formatNDJSON, true
+	case "":
+	default:
+		return "", false
+	}
+
+	format, ok := negotiate(r)
+	if !ok {
+		return "", false
+	}
+	switch format {
+	case formatJSON, formatNDJSON:
+		return formatNDJSON, true
+	case formatCSV:
+		return formatCSV, true
+	}
+	return "", false
+}
+
+// rowEncoder writes one record at a time in the export format
+type rowEncoder struct {
+	format string
+	json   *json.Encoder
+	csv    *csv.Writer
+}
+
+// newRowEncoder prepares an encoder. For CSV the header row is written
+// straight away from sample so an empty export still has column names.
+func newRowEncoder(w io.Writer, format string, sample any) (*rowEncoder, error) {
+	enc := &rowEncoder{format: format}
+	if format == formatCSV {
+		enc.csv = csv.NewWriter(w)
+		names, _ := jsonFields(reflect.ValueOf(sample))
+		err := enc.csv.Write(names)
+		if err != nil {
+			return nil, err
+		}
+		return enc, nil
+	}
+	enc.json = json.NewEncoder(w)
+	return enc, nil
+}
+
+func (e *rowEncoder) encode(record any) error {
+	if e.csv == nil {
+		return e.json.Encode(record)
+	}
+	_, values := jsonFields(reflect.ValueOf(record))
+	line := make([]string, len(values))
+	for i, fv := range values {
+		line[i] = scalarText(fv)
+	}
+	return e.csv.Write(line)
+}
+
+func (e *rowEncoder) flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+// startExport writes the response headers for a streamed export. The row
+// count and final status are sent as trailers once the stream ends.
+func (a *applicationDependencies) startExport(w http.ResponseWriter, format string, name string) *http.ResponseController {
+	rc := http.NewResponseController(w)
+	// an export can run far longer than the server-wide WriteTimeout
+	err := rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		a.logger.Warn("could not clear write deadline for export", "error", err.Error())
+	}
+
+	extension := "ndjson"
+	if format == formatCSV {
+		extension = "csv"
+	}
+
+	w.Header().Set("Trailer", "X-Export-Rows, X-Export-Status")
+	w.Header().Set("Content-Type", format+"; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, extension))
+	w.WriteHeader(http.StatusOK)
+	return rc
+}
+
+// finishExport flushes the encoder and reports the outcome in trailers.
+// Headers are already sent, so a failure can only be logged and signalled there.
+func (a *applicationDependencies) finishExport(w http.ResponseWriter, r *http.Request, enc *rowEncoder, rows int, err error) {
+	if err == nil {
+		err = enc.flush()
+	}
+
+	w.Header().Set("X-Export-Rows", strconv.Itoa(rows))
+	if err != nil {
+		a.logError(r, err)
+		w.Header().Set("X-Export-Status", "error")
+		return
+	}
+	w.Header().Set("X-Export-Status", "complete")
+}
+
+func (a *applicationDependencies) exportProductsHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(r)
+	if !ok {
+		a.notAcceptableResponse(w, r)
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	category := r.URL.Query().Get("category")
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = data.ProductActive
+	}
+	filters := data.Filters{
+		Sort: r.URL.Query().Get("sort"),
+	}
+
+	v := validator.New()
+	readPriceFilters(r, &filters, v)
+	filters.ValidateSort(v, data.ProductSortSafelist)
+	v.Check(slices.Contains(data.ProductStatuses, status), "status", "must be draft, active or archived")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	if !a.allowProductStatus(w, r, status) {
+		return
+	}
+
+	rc := a.startExport(w, format, "products")
+	enc, err := newRowEncoder(w, format, &data.Product{})
+	if err != nil {
+		a.finishExport(w, r, enc, 0, err)
+		return
+	}
+
+	rows := 0
+	err = a.productModel.Export(r.Context(), name, category, status, filters, func(product *data.Product) error {
+		err := enc.encode(product)
+		if err != nil {
+			return err
+		}
+		rows++
+		if rows%flushEvery == 0 {
+			err = enc.flush()
+			if err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+
+	a.finishExport(w, r, enc, rows, err)
+}
+
+func (a *applicationDependencies) exportReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(r)
+	if !ok {
+		a.notAcceptableResponse(w, r)
+		return
+	}
+
+	productID, _ := strconv.ParseInt(r.URL.Query().Get("product_id"), 10, 64)
+	filters := data.Filters{
+		Sort: r.URL.Query().Get("sort"),
+	}
+
+	v := validator.New()
+	filters.ValidateSort(v, data.ReviewSortSafelist)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	rc := a.startExport(w, format, "reviews")
+	enc, err := newRowEncoder(w, format, &data.Review{})
+	if err != nil {
+		a.finishExport(w, r, enc, 0, err)
+		return
+	}
+
+	rows := 0
+	err = a.reviewModel.Export(r.Context(), productID, filters.Sort, func(review *data.Review) error {
+		err := enc.encode(review)
+		if err != nil {
+			return err
+		}
+		rows++
+		if rows%flushEvery == 0 {
+			err = enc.flush()
+			if err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+
+	a.finishExport(w, r, enc, rows, err)
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// graphQLRequest is the body of a GraphQL call
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+	Extensions    map[string]any `json:"extensions"`
+}
+
+// reviewPage identifies one shape of the nested Product.reviews field
+type reviewPage struct {
+	sort  string
+	limit int
+}
+
+// productPage identifies one shape of the nested Category.products field
+type productPage struct {
+	sort   string
+	limit  int
+	offset int
+}
+
+// graphQLLoaders holds the per-request dataloaders so nested fields are
+// fetched once per level of the query rather than once per parent
+type graphQLLoaders struct {
+	products   *loader[int64, *data.Product]
+	histograms *loader[int64, []data.RatingCount]
+
+	mu               sync.Mutex
+	reviews          map[reviewPage]*loader[int64, []*data.Review]
+	categoryProducts map[productPage]*loader[string, []*data.Product]
+}
+
+type graphQLLoadersKey struct{}
+
+func (a *applicationDependencies) newGraphQLLoaders() *graphQLLoaders {
+	return &graphQLLoaders{
+		products:         newLoader(a.publishedProducts),
+		histograms:       newLoader(a.reviewModel.RatingHistograms),
+		reviews:          make(map[reviewPage]*loader[int64, []*data.Review]),
+		categoryProducts: make(map[productPage]*loader[string, []*data.Product]),
+	}
+}
+
+// publishedProducts loads products by ID for the GraphQL API, which has no
+// editors, so drafts are left out as though they didn't exist
+func (a *applicationDependencies) publishedProducts(ids []int64) (map[int64]*data.Product, error) {
+	products, err := a.productModel.GetMany(ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, product := range products {
+		if product.Status == data.ProductDraft {
+			delete(products, id)
+		}
+	}
+	return products, nil
+}
+
+// reviewsLoader returns the loader for one combination of sort and limit
+func (l *graphQLLoaders) reviewsLoader(a *applicationDependencies, page reviewPage) *loader[int64, []*data.Review] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rl, ok := l.reviews[page]
+	if !ok {
+		rl = newLoader(func(ids []int64) (map[int64][]*data.Review, error) {
+			return a.reviewModel.TopForProducts(ids, page.sort, page.limit)
+		})
+		l.reviews[page] = rl
+	}
+	return rl
+}
+
+// categoryProductsLoader returns the loader for one combination of sort,
+// limit and offset, keyed by category name
+func (l *graphQLLoaders) categoryProductsLoader(a *applicationDependencies, page productPage) *loader[string, []*data.Product] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pl, ok := l.categoryProducts[page]
+	if !ok {
+		pl = newLoader(func(categories []string) (map[string][]*data.Product, error) {
+			filters := data.Filters{Sort: page.sort, Limit: page.limit, Offset: page.offset}
+			return a.productModel.ForCategories(categories, filters)
+		})
+		l.categoryProducts[page] = pl
+	}
+	return pl
+}
+
+func loadersFrom(ctx context.Context) *graphQLLoaders {
+	return ctx.Value(graphQLLoadersKey{}).(*graphQLLoaders)
+}
+
+// listFilters maps GraphQL list arguments onto data.Filters and checks them
+// with the same validator the REST list endpoints use
+func listFilters(args map[string]any, sortSafelist []string) (data.Filters, error) {
+	filters := data.Filters{Limit: 10}
+	if sort, ok := args["sort"].(string); ok {
+		filters.Sort = sort
+	}
+	if limit, ok := args["limit"].(int); ok {
+		filters.Limit = limit
+	}
+	if offset, ok := args["offset"].(int); ok {
+		filters.Offset = offset
+	}
+	if minPrice, ok := args["min_price"].(int); ok {
+		price := int64(minPrice)
+		filters.MinPrice = &price
+	}
+	if maxPrice, ok := args["max_price"].(int); ok {
+		price := int64(maxPrice)
+		filters.MaxPrice = &price
+	}
+	if inStock, ok := args["in_stock"].(bool); ok {
+		filters.InStock = &inStock
+	}
+
+	v := validator.New()
+	filters.ValidateSort(v, sortSafelist)
+	if message, ok := v.Errors["sort"]; ok {
+		return filters, errors.New(message)
+	}
+	filters.ValidatePriceRange(v)
+	for key, message := range v.Errors {
+		return filters, fmt.Errorf("%s %s", key, message)
+	}
+	filters.ValidateFilter()
+	return filters, nil
+}
+
+// graphQLSchema builds the schema over products, reviews and categories
+func (a *applicationDependencies) graphQLSchema() (graphql.Schema, error) {
+	productSortArg := &graphql.ArgumentConfig{Type: graphql.String, Description: "rating, date or price"}
+	reviewSortArg := &graphql.ArgumentConfig{Type: graphql.String, Description: "helpful or date"}
+	pageArgs := graphql.FieldConfigArgument{
+		"sort":   productSortArg,
+		"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10},
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+	}
+
+	ratingCountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "RatingCount",
+		Fields: graphql.Fields{
+			"rating": &graphql.Field{Type: graphql.Int},
+			"count":  &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	responseType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ReviewResponse",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"content":    &graphql.Field{Type: graphql.String},
+			"created_at": &graphql.Field{Type: graphql.DateTime},
+			"updated_at": &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	mediaType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ReviewMedia",
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"content_type":  &graphql.Field{Type: graphql.String},
+			"width":         &graphql.Field{Type: graphql.Int},
+			"height":        &graphql.Field{Type: graphql.Int},
+			"url":           &graphql.Field{Type: graphql.String},
+			"thumbnail_url": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	imageVariantType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ImageVariant",
+		Fields: graphql.Fields{
+			"size":   &graphql.Field{Type: graphql.Int},
+			"width":  &graphql.Field{Type: graphql.Int},
+			"height": &graphql.Field{Type: graphql.Int},
+			"url":    &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	productImageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ProductImage",
+		Fields: graphql.Fields{
+			"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"position":     &graphql.Field{Type: graphql.Int},
+			"content_type": &graphql.Field{Type: graphql.String},
+			"width":        &graphql.Field{Type: graphql.Int},
+			"height":       &graphql.Field{Type: graphql.Int},
+			"url":          &graphql.Field{Type: graphql.String},
+			"variants":     &graphql.Field{Type: graphql.NewList(imageVariantType)},
+		},
+	})
+
+	var productType *graphql.Object
+
+	reviewType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Review",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"product_id":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"variant_id":    &graphql.Field{Type: graphql.Int, Description: "the variant reviewed, if the reviewer named one"},
+				"content":       &graphql.Field{Type: graphql.String},
+				"author":        &graphql.Field{Type: graphql.String},
+				"rating":        &graphql.Field{Type: graphql.Int},
+				"helpful_count": &graphql.Field{Type: graphql.Int},
+				"edited":        &graphql.Field{Type: graphql.Boolean},
+				"response":      &graphql.Field{Type: responseType, Description: "the seller's official response, if any"},
+				"media":         &graphql.Field{Type: graphql.NewList(mediaType), Description: "images the reviewer attached"},
+				"product": &graphql.Field{
+					Type: productType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						review := p.Source.(*data.Review)
+						return loadersFrom(p.Context).products.load(review.ProductID), nil
+					},
+				},
+			}
+		}),
+	})
+
+	productType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id":             &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+			"name":           &graphql.Field{Type: graphql.String},
+			"description":    &graphql.Field{Type: graphql.String},
+			"category":       &graphql.Field{Type: graphql.String},
+			"image_url":      &graphql.Field{Type: graphql.String},
+			"external_id":    &graphql.Field{Type: graphql.String},
+			"average_rating": &graphql.Field{Type: graphql.Float},
+			"status":         &graphql.Field{Type: graphql.String},
+			"price":          &graphql.Field{Type: graphql.Int, Description: "in minor units of currency"},
+			"currency":       &graphql.Field{Type: graphql.String},
+			"availability":   &graphql.Field{Type: graphql.String},
+			"images":         &graphql.Field{Type: graphql.NewList(productImageType), Description: "uploaded pictures of the product in order"},
+			"reviews": &graphql.Field{
+				Type:        graphql.NewList(reviewType),
+				Description: "the product's top reviews, most helpful first unless sort is date",
+				Args: graphql.FieldConfigArgument{
+					"sort":  reviewSortArg,
+					"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 3},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					product := p.Source.(*data.Product)
+					filters, err := listFilters(p.Args, data.ReviewSortSafelist)
+					if err != nil {
+						return nil, err
+					}
+					page := reviewPage{sort: filters.Sort, limit: filters.Limit}
+					return loadersFrom(p.Context).reviewsLoader(a, page).load(product.ID), nil
+				},
+			},
+			"rating_histogram": &graphql.Field{
+				Type: graphql.NewList(ratingCountType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					product := p.Source.(*data.Product)
+					return loadersFrom(p.Context).histograms.load(product.ID), nil
+				},
+			},
+		},
+	})
+
+	categoryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.Fields{
+			"name":          &graphql.Field{Type: graphql.String},
+			"product_count": &graphql.Field{Type: graphql.Int},
+			"products": &graphql.Field{
+				Type: graphql.NewList(productType),
+				Args: pageArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					category := p.Source.(*data.CategoryCount)
+					filters, err := listFilters(p.Args, data.ProductSortSafelist)
+					if err != nil {
+						return nil, err
+					}
+					page := productPage{sort: filters.Sort, limit: filters.Limit, offset: filters.Offset}
+					return loadersFrom(p.Context).categoryProductsLoader(a, page).load(category.Name), nil
+				},
+			},
+		},
+	})
+
+	productArgs := graphql.FieldConfigArgument{
+		"name":      &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+		"category":  &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: ""},
+		"min_price": &graphql.ArgumentConfig{Type: graphql.Int, Description: "in minor units"},
+		"max_price": &graphql.ArgumentConfig{Type: graphql.Int, Description: "in minor units"},
+		"in_stock":  &graphql.ArgumentConfig{Type: graphql.Boolean},
+	}
+	for name, arg := range pageArgs {
+		productArgs[name] = arg
+	}
+	reviewArgs := graphql.FieldConfigArgument{
+		"product_id": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+		"variant_id": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+	}
+	for name, arg := range pageArgs {
+		reviewArgs[name] = arg
+	}
+	reviewArgs["sort"] = reviewSortArg
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"product": &graphql.Field{
+				Type: productType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return loadersFrom(p.Context).products.load(int64(p.Args["id"].(int))), nil
+				},
+			},
+			"products": &graphql.Field{
+				Type: graphql.NewList(productType),
+				Args: productArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					filters, err := listFilters(p.Args, data.ProductSortSafelist)
+					if err != nil {
+						return nil, err
+					}
+					return a.productModel.GetAll(p.Args["name"].(string), p.Args["category"].(string), data.ProductActive, filters)
+				},
+			},
+			"review": &graphql.Field{
+				Type: reviewType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					review, err := a.reviewModel.Get(int64(p.Args["id"].(int)))
+					if err != nil && err.Error() == "review not found" {
+						return nil, nil
+					}
+					if err == nil && review.Status != data.ReviewApproved {
+						return nil, nil
+					}
+					return review, err
+				},
+			},
+			"reviews": &graphql.Field{
+				Type: graphql.NewList(reviewType),
+				Args: reviewArgs,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					filters, err := listFilters(p.Args, data.ReviewSortSafelist)
+					if err != nil {
+						return nil, err
+					}
+					productID := int64(p.Args["product_id"].(int))
+					variantID := int64(p.Args["variant_id"].(int))
+					return a.reviewModel.GetAll(productID, variantID, filters.Sort, filters.Limit, filters.Offset)
+				},
+			},
+			"categories": &graphql.Field{
+				Type: graphql.NewList(categoryType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return a.productModel.Categories()
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// graphQLHandler serves GraphQL queries over GET and POST. The schema is
+// built once; the dataloaders are created fresh for every request. Queries
+// nested deeper or estimated to cost more than the configured limits are
+// refused before they run, since reviews and products nest inside each
+// other without end.
+func (a *applicationDependencies) graphQLHandler() http.HandlerFunc {
+	schema, schemaErr := a.graphQLSchema()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if schemaErr != nil {
+			a.serverErrorResponse(w, r, schemaErr)
+			return
+		}
+
+		var input graphQLRequest
+		if r.Method == http.MethodGet {
+			input.Query = r.URL.Query().Get("query")
+			input.OperationName = r.URL.Query().Get("operationName")
+		} else {
+			err := a.readJSON(w, r, &input)
+			if err != nil {
+				a.badRequestResponse(w, r, err)
+				return
+			}
+		}
+
+		v := validator.New()
+		v.Check(input.Query != "", "query", "must be provided")
+		if !v.IsEmpty() {
+			a.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		// a query that doesn't parse is left for graphql.Do to report
+		document, err := parser.Parse(parser.ParseParams{Source: input.Query})
+		if err == nil {
+			depth, cost := measureQuery(schema, document, input.Variables)
+			maxDepth, maxCost := a.config.graphql.maxDepth, a.config.graphql.maxCost
+			if maxDepth > 0 && depth > maxDepth {
+				v.AddError("query", fmt.Sprintf("must not nest more than %d levels deep; this query nests %d", maxDepth, depth))
+			} else if maxCost > 0 && cost > maxCost {
+				v.AddError("query", fmt.Sprintf("must not have an estimated cost over %d; this query's is %d", maxCost, cost))
+			}
+		}
+		if !v.IsEmpty() {
+			a.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), graphQLLoadersKey{}, a.newGraphQLLoaders())
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  input.Query,
+			OperationName:  input.OperationName,
+			VariableValues: input.Variables,
+			Context:        ctx,
+		})
+
+		err = a.writeJSON(w, http.StatusOK, result, nil)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+		}
+	}
+}
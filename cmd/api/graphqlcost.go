@@ -0,0 +1,177 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// graphQLListEstimate is the number of items assumed for a list field that
+// takes no limit argument, such as categories or a product's images
+const graphQLListEstimate = 10
+
+// queryMeasure walks a parsed GraphQL document alongside the schema to
+// find how deeply it nests and roughly how much work it asks for
+type queryMeasure struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+}
+
+// measureQuery returns the depth and estimated cost of the deepest and
+// most expensive operation in document. Every field costs one, and the
+// selections of a list field are counted once for each item it can
+// return: its limit, as the resolvers will clamp it, or
+// graphQLListEstimate for a list without one. Introspection fields are
+// not counted.
+func measureQuery(schema graphql.Schema, document *ast.Document, variables map[string]any) (depth int, cost int) {
+	m := &queryMeasure{
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: variables,
+	}
+	for _, definition := range document.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok && fragment.Name != nil {
+			m.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, definition := range document.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		var root *graphql.Object
+		switch operation.Operation {
+		case ast.OperationTypeMutation:
+			root = schema.MutationType()
+		case ast.OperationTypeSubscription:
+			root = schema.SubscriptionType()
+		default:
+			root = schema.QueryType()
+		}
+		d, c := m.selections(root, operation.SelectionSet, make(map[string]bool))
+		depth = max(depth, d)
+		cost = max(cost, c)
+	}
+	return depth, cost
+}
+
+// selections measures a selection set on parent, which is nil when the
+// type isn't known. visiting holds the fragments being expanded, so a
+// fragment that spreads itself is only counted once; validation rejects it
+// later.
+func (m *queryMeasure) selections(parent *graphql.Object, set *ast.SelectionSet, visiting map[string]bool) (depth int, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, selection := range set.Selections {
+		var d, c int
+		switch s := selection.(type) {
+		case *ast.Field:
+			d, c = m.field(parent, s, visiting)
+		case *ast.InlineFragment:
+			d, c = m.selections(m.fragmentType(parent, s.TypeCondition), s.SelectionSet, visiting)
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			fragment, ok := m.fragments[name]
+			if !ok || visiting[name] {
+				continue
+			}
+			visiting[name] = true
+			d, c = m.selections(m.fragmentType(parent, fragment.TypeCondition), fragment.SelectionSet, visiting)
+			delete(visiting, name)
+		}
+		depth = max(depth, d)
+		cost = min(cost+c, math.MaxInt32)
+	}
+	return depth, cost
+}
+
+func (m *queryMeasure) field(parent *graphql.Object, field *ast.Field, visiting map[string]bool) (depth int, cost int) {
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return 0, 0
+	}
+
+	var definition *graphql.FieldDefinition
+	if parent != nil {
+		definition = parent.Fields()[field.Name.Value]
+	}
+
+	items := 1
+	var child *graphql.Object
+	if definition != nil {
+		fieldType := unwrapNonNull(definition.Type)
+		if list, ok := fieldType.(*graphql.List); ok {
+			items = m.listSize(field, definition)
+			fieldType = unwrapNonNull(list.OfType)
+		}
+		child, _ = fieldType.(*graphql.Object)
+	}
+
+	depth, cost = m.selections(child, field.SelectionSet, visiting)
+	// the cap keeps absurd queries from overflowing
+	return depth + 1, min(1+items*cost, math.MaxInt32)
+}
+
+// listSize returns the number of items a list field can return
+func (m *queryMeasure) listSize(field *ast.Field, definition *graphql.FieldDefinition) int {
+	var limitArg *graphql.Argument
+	for _, arg := range definition.Args {
+		if arg.Name() == "limit" {
+			limitArg = arg
+		}
+	}
+	if limitArg == nil {
+		return graphQLListEstimate
+	}
+
+	limit, _ := limitArg.DefaultValue.(int)
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch value := arg.Value.(type) {
+		case *ast.IntValue:
+			limit, _ = strconv.Atoi(value.Value)
+		case *ast.Variable:
+			// variables arrive as JSON numbers; assume the largest page
+			// for one that wasn't sent
+			switch v := m.variables[value.Name.Value].(type) {
+			case float64:
+				limit = int(v)
+			case int:
+				limit = v
+			default:
+				limit = 100
+			}
+		}
+	}
+
+	filters := data.Filters{Limit: limit}
+	filters.ValidateFilter()
+	return filters.Limit
+}
+
+// fragmentType returns the object type a fragment applies to
+func (m *queryMeasure) fragmentType(parent *graphql.Object, condition *ast.Named) *graphql.Object {
+	if condition == nil || condition.Name == nil {
+		return parent
+	}
+	if object, ok := m.schema.Type(condition.Name.Value).(*graphql.Object); ok {
+		return object
+	}
+	return parent
+}
+
+func unwrapNonNull(t graphql.Type) graphql.Type {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		return nonNull.OfType
+	}
+	return t
+}
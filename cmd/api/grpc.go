@@ -0,0 +1,595 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/pb"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errStopStream ends an Export early once a List call's limit is reached
+var errStopStream = errors.New("stop stream")
+
+// productServer implements pb.ProductServiceServer on top of the same
+// models the HTTP handlers use
+type productServer struct {
+	pb.UnimplementedProductServiceServer
+	app *applicationDependencies
+}
+
+// reviewServer implements pb.ReviewServiceServer
+type reviewServer struct {
+	pb.UnimplementedReviewServiceServer
+	app *applicationDependencies
+}
+
+// newGRPCServer builds the gRPC server with both services registered
+func (a *applicationDependencies) newGRPCServer() *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(a.grpcRecoverUnary, a.grpcRateLimitUnary, a.grpcAuthenticateUnary, a.grpcAuthorizeUnary),
+		grpc.ChainStreamInterceptor(a.grpcRecoverStream, a.grpcRateLimitStream, a.grpcAuthenticateStream, a.grpcAuthorizeStream),
+	)
+	pb.RegisterProductServiceServer(server, &productServer{app: a})
+	pb.RegisterReviewServiceServer(server, &reviewServer{app: a})
+	return server
+}
+
+// grpcRecoverUnary is the gRPC counterpart of recoverPanic
+func (a *applicationDependencies) grpcRecoverUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			a.logger.Error(fmt.Sprintf("%v", p), "method", info.FullMethod, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+func (a *applicationDependencies) grpcRecoverStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			a.logger.Error(fmt.Sprintf("%v", p), "method", info.FullMethod, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+		}
+	}()
+	return handler(srv, ss)
+}
+
+// grpcRateLimit applies the HTTP server's per-IP rate limiter to a call
+func (a *applicationDependencies) grpcRateLimit(ctx context.Context, method string) error {
+	if !a.config.limiter.enabled {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return a.grpcServerError(method, errors.New("missing peer in call context"))
+	}
+	ip, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return a.grpcServerError(method, err)
+	}
+	if a.limiter.allow(ip) {
+		return nil
+	}
+
+	// tell clients how long until the limiter has a token for them again,
+	// as Retry-After does over HTTP
+	retryAfter := time.Second
+	if a.config.limiter.rps > 0 {
+		retryAfter = time.Duration(math.Ceil(1/a.config.limiter.rps)) * time.Second
+	}
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded")
+	withDetails, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+func (a *applicationDependencies) grpcRateLimitUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	err := a.grpcRateLimit(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *applicationDependencies) grpcRateLimitStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := a.grpcRateLimit(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// grpcAuthenticate is the gRPC counterpart of authenticate. It resolves the
+// bearer token in the call's "authorization" metadata and returns a context
+// carrying the user, or the anonymous user if there is no token.
+func (a *applicationDependencies) grpcAuthenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return contextWithUser(ctx, data.AnonymousUser), nil
+	}
+
+	invalid := status.Error(codes.Unauthenticated, "invalid or missing authentication token")
+	headerParts := strings.Split(values[0], " ")
+	if len(values) != 1 || len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return nil, invalid
+	}
+	token := headerParts[1]
+
+	v := validator.New()
+	data.ValidateTokenPlaintext(v, token)
+	if !v.IsEmpty() {
+		return nil, invalid
+	}
+
+	user, err := a.userModel.GetForToken(data.ScopeAuthentication, token)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, invalid
+		}
+		return nil, a.grpcServerError(method, err)
+	}
+	return contextWithUser(ctx, user), nil
+}
+
+func (a *applicationDependencies) grpcAuthenticateUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.grpcAuthenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *applicationDependencies) grpcAuthenticateStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.grpcAuthenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream hands a stream handler the context carrying its user
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcPermissions names the permission a gRPC method needs, as the HTTP
+// routes wrap their handlers in requirePermission. Methods not listed are
+// open to anonymous callers.
+var grpcPermissions = map[string]string{
+	pb.ProductService_CreateProduct_FullMethodName: "products:edit",
+	pb.ProductService_UpdateProduct_FullMethodName: "products:edit",
+	pb.ProductService_DeleteProduct_FullMethodName: "products:edit",
+}
+
+// grpcAuthorize is the gRPC counterpart of requirePermission. It rejects
+// calls to a method in grpcPermissions from users without its permission.
+func (a *applicationDependencies) grpcAuthorize(ctx context.Context, method string) error {
+	code, ok := grpcPermissions[method]
+	if !ok {
+		return nil
+	}
+
+	user := userFromContext(ctx)
+	if user.IsAnonymous() {
+		return status.Error(codes.Unauthenticated, "you must be authenticated to access this resource")
+	}
+	permitted, err := a.userHasPermission(user, code)
+	if err != nil {
+		return a.grpcServerError(method, err)
+	}
+	if !permitted {
+		return status.Error(codes.PermissionDenied, "your user account doesn't have the necessary permissions to access this resource")
+	}
+	return nil
+}
+
+func (a *applicationDependencies) grpcAuthorizeUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	err := a.grpcAuthorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *applicationDependencies) grpcAuthorizeStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	err := a.grpcAuthorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// grpcServerError logs err and hides it behind a generic Internal status
+func (a *applicationDependencies) grpcServerError(method string, err error) error {
+	a.logger.Error(err.Error(), "method", method)
+	return status.Error(codes.Internal, "the server encountered a problem and could not process your request")
+}
+
+// grpcCatalogError is the gRPC counterpart of catalogErrorResponse
+func (a *applicationDependencies) grpcCatalogError(method string, err error) error {
+	var failed *validationError
+	switch {
+	case errors.As(err, &failed):
+		return grpcValidationError(failed.errors)
+	case errors.Is(err, data.ErrDuplicateReview):
+		return status.Error(codes.AlreadyExists, "this author has already reviewed this product")
+	default:
+		return a.grpcServerError(method, err)
+	}
+}
+
+// grpcValidationError turns validator errors into InvalidArgument with a
+// BadRequest detail listing every failed field
+func grpcValidationError(errs map[string]string) error {
+	st := status.New(codes.InvalidArgument, "one or more fields failed validation")
+	badRequest := &errdetails.BadRequest{}
+	for field, message := range errs {
+		badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: message,
+		})
+	}
+	withDetails, err := st.WithDetails(badRequest)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+func productToPB(p *data.Product) *pb.Product {
+	return &pb.Product{
+		Id:            p.ID,
+		Name:          p.Name,
+		Description:   p.Description,
+		Category:      p.Category,
+		ImageUrl:      p.ImageURL,
+		ExternalId:    p.ExternalID,
+		AverageRating: p.AverageRating,
+		Status:        p.Status,
+		Price:         p.Price,
+		Currency:      p.Currency,
+		Availability:  p.Availability,
+	}
+}
+
+func reviewToPB(r *data.Review) *pb.Review {
+	return &pb.Review{
+		Id:           r.ID,
+		ProductId:    r.ProductID,
+		Content:      r.Content,
+		Author:       r.Author,
+		Rating:       int32(r.Rating),
+		HelpfulCount: int32(r.HelpfulCount),
+		Status:       r.Status,
+		Edited:       r.Edited,
+		VariantId:    r.VariantID,
+	}
+}
+
+// grpcVisibleProduct loads a product for the caller. As over HTTP, drafts are only
+// visible to editors.
+func (a *applicationDependencies) grpcVisibleProduct(ctx context.Context, method string, id int64) (*data.Product, error) {
+	product, err := a.visibleProduct(userFromContext(ctx), id)
+	if err != nil {
+		if err.Error() == "product not found" {
+			return nil, status.Error(codes.NotFound, "the requested resource could not be found")
+		}
+		return nil, a.grpcServerError(method, err)
+	}
+	return product, nil
+}
+
+func (s *productServer) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.Product, error) {
+	product, err := s.app.grpcVisibleProduct(ctx, "GetProduct", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return productToPB(product), nil
+}
+
+func (s *productServer) ListProducts(req *pb.ListProductsRequest, stream pb.ProductService_ListProductsServer) error {
+	filters := data.Filters{Sort: req.GetSort(), MinPrice: req.MinPrice, MaxPrice: req.MaxPrice, InStock: req.InStock}
+	productStatus := req.GetStatus()
+	if productStatus == "" {
+		productStatus = data.ProductActive
+	}
+	v := validator.New()
+	filters.ValidateSort(v, data.ProductSortSafelist)
+	filters.ValidatePriceRange(v)
+	v.Check(req.GetLimit() >= 0, "limit", "must not be negative")
+	v.Check(slices.Contains(data.ProductStatuses, productStatus), "status", "must be draft, active or archived")
+	if !v.IsEmpty() {
+		return grpcValidationError(v.Errors)
+	}
+	visible, err := s.app.productStatusVisible(userFromContext(stream.Context()), productStatus)
+	if err != nil {
+		return s.app.grpcServerError("ListProducts", err)
+	}
+	if !visible {
+		return status.Error(codes.PermissionDenied, "drafts are only visible to editors")
+	}
+
+	sent := int32(0)
+	err = s.app.productModel.Export(stream.Context(), req.GetName(), req.GetCategory(), productStatus, filters, func(product *data.Product) error {
+		err := stream.Send(productToPB(product))
+		if err != nil {
+			return err
+		}
+		sent++
+		if req.GetLimit() > 0 && sent >= req.GetLimit() {
+			return errStopStream
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopStream) {
+		if stream.Context().Err() != nil {
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+		return s.app.grpcServerError("ListProducts", err)
+	}
+	return nil
+}
+
+func (s *productServer) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.Product, error) {
+	product := &data.Product{
+		Name:         req.GetName(),
+		Description:  req.GetDescription(),
+		Category:     req.GetCategory(),
+		ImageURL:     req.GetImageUrl(),
+		Status:       req.GetStatus(),
+		Price:        req.GetPrice(),
+		Currency:     req.GetCurrency(),
+		Availability: req.GetAvailability(),
+	}
+
+	err := s.app.createProduct(ctx, product)
+	if err != nil {
+		return nil, s.app.grpcCatalogError("CreateProduct", err)
+	}
+	return productToPB(product), nil
+}
+
+func (s *productServer) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (*pb.Product, error) {
+	product, err := s.app.grpcVisibleProduct(ctx, "UpdateProduct", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	previous := *product
+
+	if req.Name != nil {
+		product.Name = req.GetName()
+	}
+	if req.Description != nil {
+		product.Description = req.GetDescription()
+	}
+	if req.Category != nil {
+		product.Category = req.GetCategory()
+	}
+	if req.ImageUrl != nil {
+		product.ImageURL = req.GetImageUrl()
+	}
+	if req.Status != nil {
+		product.Status = req.GetStatus()
+	}
+	if req.Price != nil {
+		product.Price = req.GetPrice()
+	}
+	if req.Currency != nil {
+		product.Currency = req.GetCurrency()
+	}
+	if req.Availability != nil {
+		product.Availability = req.GetAvailability()
+	}
+
+	err = s.app.updateProduct(ctx, userFromContext(ctx), &previous, product)
+	if err != nil {
+		return nil, s.app.grpcCatalogError("UpdateProduct", err)
+	}
+	return productToPB(product), nil
+}
+
+func (s *productServer) DeleteProduct(ctx context.Context, req *pb.DeleteProductRequest) (*pb.DeleteProductResponse, error) {
+	_, err := s.app.grpcVisibleProduct(ctx, "DeleteProduct", req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.app.deleteProduct(ctx, req.GetId())
+	if err != nil {
+		return nil, s.app.grpcServerError("DeleteProduct", err)
+	}
+	return &pb.DeleteProductResponse{}, nil
+}
+
+// ownedReview loads a review for its author, or for a reviews:moderate
+// user. Like the HTTP API, anyone else gets NotFound for a review that
+// isn't approved and PermissionDenied for one that is.
+func (s *reviewServer) ownedReview(ctx context.Context, method string, productID int64, id int64) (*data.Review, error) {
+	user := userFromContext(ctx)
+	if user.IsAnonymous() {
+		return nil, status.Error(codes.Unauthenticated, "you must be authenticated to access this resource")
+	}
+
+	review, err := s.getReview(method, productID, id)
+	if err != nil {
+		return nil, err
+	}
+	if review.UserID != 0 && review.UserID == user.ID {
+		return review, nil
+	}
+	permitted, err := s.app.userHasPermission(user, "reviews:moderate")
+	if err != nil {
+		return nil, s.app.grpcServerError(method, err)
+	}
+	if permitted {
+		return review, nil
+	}
+	if review.Status != data.ReviewApproved {
+		return nil, status.Error(codes.NotFound, "the requested resource could not be found")
+	}
+	return nil, status.Error(codes.PermissionDenied, "your user account doesn't have the necessary permissions to access this resource")
+}
+
+// getReview loads a review and checks it belongs to productID
+func (s *reviewServer) getReview(method string, productID int64, id int64) (*data.Review, error) {
+	review, err := s.app.reviewModel.Get(id)
+	if err != nil && err.Error() != "review not found" {
+		return nil, s.app.grpcServerError(method, err)
+	}
+	if err != nil || review.ProductID != productID {
+		return nil, status.Error(codes.NotFound, "the requested resource could not be found")
+	}
+	return review, nil
+}
+
+func (s *reviewServer) GetReview(ctx context.Context, req *pb.GetReviewRequest) (*pb.Review, error) {
+	review, err := s.getReview("GetReview", req.GetProductId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if review.Status != data.ReviewApproved {
+		return nil, status.Error(codes.NotFound, "the requested resource could not be found")
+	}
+	return reviewToPB(review), nil
+}
+
+func (s *reviewServer) ListReviews(req *pb.ListReviewsRequest, stream pb.ReviewService_ListReviewsServer) error {
+	filters := data.Filters{Sort: req.GetSort()}
+	v := validator.New()
+	filters.ValidateSort(v, data.ReviewSortSafelist)
+	v.Check(req.GetLimit() >= 0, "limit", "must not be negative")
+	if !v.IsEmpty() {
+		return grpcValidationError(v.Errors)
+	}
+
+	sent := int32(0)
+	err := s.app.reviewModel.Export(stream.Context(), req.GetProductId(), filters.Sort, func(review *data.Review) error {
+		err := stream.Send(reviewToPB(review))
+		if err != nil {
+			return err
+		}
+		sent++
+		if req.GetLimit() > 0 && sent >= req.GetLimit() {
+			return errStopStream
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopStream) {
+		if stream.Context().Err() != nil {
+			return status.FromContextError(stream.Context().Err()).Err()
+		}
+		return s.app.grpcServerError("ListReviews", err)
+	}
+	return nil
+}
+
+func (s *reviewServer) CreateReview(ctx context.Context, req *pb.CreateReviewRequest) (*pb.Review, error) {
+	product, err := s.app.grpcVisibleProduct(ctx, "CreateReview", req.GetProductId())
+	if err != nil {
+		return nil, err
+	}
+
+	review := &data.Review{
+		ProductID: req.GetProductId(),
+		Content:   req.GetContent(),
+		Author:    req.GetAuthor(),
+		Rating:    int(req.GetRating()),
+		VariantID: req.GetVariantId(),
+	}
+	// a signed-in reviewer's review is theirs, which limits them to one per product
+	if user := userFromContext(ctx); !user.IsAnonymous() {
+		review.UserID = user.ID
+	}
+
+	err = s.app.createReview(ctx, product, review)
+	if err != nil {
+		return nil, s.app.grpcCatalogError("CreateReview", err)
+	}
+	return reviewToPB(review), nil
+}
+
+func (s *reviewServer) UpdateReview(ctx context.Context, req *pb.UpdateReviewRequest) (*pb.Review, error) {
+	review, err := s.ownedReview(ctx, "UpdateReview", req.GetProductId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	previous := *review
+
+	if req.Content != nil {
+		review.Content = req.GetContent()
+	}
+	if req.Author != nil {
+		review.Author = req.GetAuthor()
+	}
+	if req.Rating != nil {
+		review.Rating = int(req.GetRating())
+	}
+	if req.VariantId != nil {
+		review.VariantID = req.GetVariantId()
+	}
+
+	err = s.app.updateReview(ctx, userFromContext(ctx), &previous, review)
+	if err != nil {
+		return nil, s.app.grpcCatalogError("UpdateReview", err)
+	}
+	return reviewToPB(review), nil
+}
+
+func (s *reviewServer) DeleteReview(ctx context.Context, req *pb.DeleteReviewRequest) (*pb.DeleteReviewResponse, error) {
+	review, err := s.ownedReview(ctx, "DeleteReview", req.GetProductId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.app.deleteReview(ctx, review)
+	if err != nil {
+		return nil, s.app.grpcServerError("DeleteReview", err)
+	}
+	return &pb.DeleteReviewResponse{}, nil
+}
+
+func (s *reviewServer) MarkHelpful(ctx context.Context, req *pb.MarkHelpfulRequest) (*pb.Review, error) {
+	user := userFromContext(ctx)
+	if user.IsAnonymous() {
+		return nil, status.Error(codes.Unauthenticated, "you must be authenticated to access this resource")
+	}
+
+	review, err := s.getReview("MarkHelpful", req.GetProductId(), req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if review.Status != data.ReviewApproved {
+		return nil, status.Error(codes.NotFound, "the requested resource could not be found")
+	}
+
+	err = s.app.markHelpful(ctx, user, review)
+	if err != nil {
+		return nil, s.app.grpcServerError("MarkHelpful", err)
+	}
+	return reviewToPB(review), nil
+}
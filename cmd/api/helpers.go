@@ -1,21 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
 
+	"github.com/RayMC17/AWT_Test1/internal/patch"
 	"github.com/julienschmidt/httprouter"
 )
 
 //create an envelope type
 type envelope map[string]any
 
-func (a *applicationDependencies)writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
+func (a *applicationDependencies)writeJSON(w http.ResponseWriter, status int, data any, headers http.Header) error {
 	jsResponse, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
 		return err
@@ -27,8 +31,10 @@ func (a *applicationDependencies)writeJSON(w http.ResponseWriter, status int, da
 		w.Header()[key] = value
 		//w.Header().Set(key, value)
 	}
-	//set content type header
-	w.Header().Set("Content-Type", "application/json")
+	//set content type header unless the caller already chose one
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	//explicitly set the response status code
 	w.WriteHeader(status)
 	_, err = w.Write(jsResponse)
@@ -105,3 +111,92 @@ func (a *applicationDependencies)readIDParam(r *http.Request)(int64, error) {
 
 }
 
+
+// Media types accepted on PATCH routes in addition to plain JSON
+const (
+	mediaMergePatch = "application/merge-patch+json"
+	mediaJSONPatch  = "application/json-patch+json"
+)
+
+// requestMediaType returns the request's Content-Type without parameters
+func requestMediaType(r *http.Request) string {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// readPatch applies a merge patch or JSON patch body to current, a pointer
+// to a struct holding the resource's writable fields. Members the patch
+// removes or sets to null come back as zero values, so clearing a field is
+// distinct from leaving it out.
+func (a *applicationDependencies) readPatch(w http.ResponseWriter, r *http.Request, mediaType string, current any) error {
+	maxBytes := 256_000
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		return err
+	}
+	if len(body) == 0 {
+		return errors.New("the body must not be empty")
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	if mediaType == mediaJSONPatch {
+		doc, err = patch.JSONPatch(doc, body)
+	} else {
+		doc, err = patch.MergePatch(doc, body)
+	}
+	if err != nil {
+		return err
+	}
+
+	// start from zero values so removed members are cleared
+	target := reflect.ValueOf(current).Elem()
+	target.Set(reflect.Zero(target.Type()))
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(current)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &unmarshalTypeError):
+			return fmt.Errorf("the patched document has the incorrect JSON type for field %q", unmarshalTypeError.Field)
+		case strings.HasPrefix(err.Error(), "json: unknown field"):
+			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
+			return fmt.Errorf("the patch adds unknown key %s", fieldName)
+		case strings.HasPrefix(err.Error(), "json: cannot unmarshal"):
+			return errors.New("the patch must produce a JSON object")
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// readReviewIDParams returns the :id product and :review_id review
+// parameters of a nested review route
+func (a *applicationDependencies) readReviewIDParams(r *http.Request) (int64, int64, error) {
+	productID, err := a.readIDParam(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	reviewID, err := strconv.ParseInt(params.ByName("review_id"), 10, 64)
+	if err != nil || reviewID < 1 {
+		return 0, 0, errors.New("invalid review_id parameter")
+	}
+
+	return productID, reviewID, nil
+}
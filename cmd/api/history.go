@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+// reviewHistoryHandler lists a review's earlier versions, newest first.
+// It covers reviews in every status so moderators can see what a review
+// said before it was edited.
+func (a *applicationDependencies) reviewHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	productID, id, err := a.readReviewIDParams(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	review, err := a.reviewModel.Get(id)
+	if err != nil || review.ProductID != productID {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	a.writeHistory(w, r, data.RevisionReview, review.ID)
+}
+
+// productHistoryHandler lists a product's earlier versions, newest first
+func (a *applicationDependencies) productHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	_, err = a.getVisibleProduct(r, id)
+	if err != nil {
+		if err.Error() == "product not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	a.writeHistory(w, r, data.RevisionProduct, id)
+}
+
+// writeHistory sends one page of a record's revisions
+func (a *applicationDependencies) writeHistory(w http.ResponseWriter, r *http.Request, kind string, id int64) {
+	qs := r.URL.Query()
+	filters := data.Filters{
+		Limit:  parseInt(qs.Get("limit"), 10),
+		Offset: parseInt(qs.Get("offset"), 0),
+	}
+
+	revisions, err := a.revisionModel.ForRecord(kind, id, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"history": revisions}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/julienschmidt/httprouter"
+)
+
+// productImageSizes are the longest sides of the resized copies made of
+// every product image, from a listing thumbnail up to a product page
+var productImageSizes = []int{160, 480, 1024}
+
+// imageOrderInput is the request body for reordering a product's images
+type imageOrderInput struct {
+	IDs []int64 `json:"ids"`
+}
+
+// readImageIDParam returns the :image_id parameter of a product image route
+func (a *applicationDependencies) readImageIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("image_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid image_id parameter")
+	}
+	return id, nil
+}
+
+// productForImages loads the product named by the route, or sends an
+// error response and returns nil
+func (a *applicationDependencies) productForImages(w http.ResponseWriter, r *http.Request) *data.Product {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return nil
+	}
+
+	product, err := a.getVisibleProduct(r, id)
+	if err != nil {
+		if err.Error() == "product not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	return product
+}
+
+// writeImages sends a product's images
+func (a *applicationDependencies) writeImages(w http.ResponseWriter, r *http.Request, status int, images []*data.ProductImage) {
+	if images == nil {
+		images = []*data.ProductImage{}
+	}
+	err := a.writeJSON(w, status, envelope{"images": images}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadProductImagesHandler adds the images in a multipart/form-data body
+// after a product's existing images, storing resized copies of each
+func (a *applicationDependencies) uploadProductImagesHandler(w http.ResponseWriter, r *http.Request) {
+	product := a.productForImages(w, r)
+	if product == nil {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		a.unsupportedMediaTypeResponse(w, r, "multipart/form-data")
+		return
+	}
+
+	a.extendDeadlines(w, uploadTimeout)
+	r.Body = http.MaxBytesReader(w, r.Body, int64(a.config.media.maxImages)*a.config.media.maxBytes+1<<20)
+	tooMany := fmt.Sprintf("a product can have at most %d images", a.config.media.maxImages)
+	images, errs, err := a.readImages(r, a.config.media.maxImages-len(product.Images), tooMany, productImageSizes)
+	if err != nil {
+		a.badRequestResponse(w, r, readUploadError(err))
+		return
+	}
+	if errs != nil {
+		a.failedValidationResponse(w, r, errs)
+		return
+	}
+
+	var keys []string
+	var added []*data.ProductImage
+	dir := "products/" + strconv.FormatInt(product.ID, 10)
+	for _, image := range images {
+		key, err := newStorageKey(dir, image.Ext)
+		if err == nil {
+			var stored []string
+			stored, err = a.storeImage(r, key, image)
+			keys = append(keys, stored...)
+		}
+		if err != nil {
+			a.deleteFiles(r, keys...)
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		productImage := &data.ProductImage{
+			ProductID:   product.ID,
+			ContentType: image.ContentType,
+			Width:       image.Width,
+			Height:      image.Height,
+			Size:        int64(len(image.Data)),
+			URL:         a.store.URL(key),
+			Key:         key,
+		}
+		for _, variant := range image.Variants {
+			productImage.Variants = append(productImage.Variants, data.ImageVariant{
+				Size:   variant.Size,
+				Width:  variant.Width,
+				Height: variant.Height,
+				URL:    a.store.URL(data.VariantKey(key, variant.Size)),
+			})
+		}
+		added = append(added, productImage)
+	}
+
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		for _, image := range added {
+			err := tx.productImageModel.Insert(image)
+			if err != nil {
+				return err
+			}
+		}
+		product.Images = append(product.Images, added...)
+		return tx.publish(data.EventProductUpdated, product)
+	})
+	if err != nil {
+		a.deleteFiles(r, keys...)
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	a.writeImages(w, r, http.StatusCreated, added)
+}
+
+// listProductImagesHandler lists a product's images in order
+func (a *applicationDependencies) listProductImagesHandler(w http.ResponseWriter, r *http.Request) {
+	product := a.productForImages(w, r)
+	if product == nil {
+		return
+	}
+
+	a.writeImages(w, r, http.StatusOK, product.Images)
+}
+
+// reorderProductImagesHandler rearranges a product's images. The body
+// lists every image ID in the new order.
+func (a *applicationDependencies) reorderProductImagesHandler(w http.ResponseWriter, r *http.Request) {
+	product := a.productForImages(w, r)
+	if product == nil {
+		return
+	}
+
+	var input imageOrderInput
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	var images []*data.ProductImage
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		err := tx.productImageModel.Reorder(product.ID, input.IDs)
+		if err != nil {
+			return err
+		}
+		images, err = tx.productImageModel.ForProduct(product.ID)
+		if err != nil {
+			return err
+		}
+		product.Images = images
+		return tx.publish(data.EventProductUpdated, product)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrImageOrder) {
+			a.failedValidationResponse(w, r, map[string]string{"ids": err.Error()})
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	a.writeImages(w, r, http.StatusOK, images)
+}
+
+// deleteProductImageHandler removes one of a product's images and its
+// resized copies
+func (a *applicationDependencies) deleteProductImageHandler(w http.ResponseWriter, r *http.Request) {
+	product := a.productForImages(w, r)
+	if product == nil {
+		return
+	}
+
+	id, err := a.readImageIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	image, err := a.productImageModel.Get(id)
+	if err != nil {
+		if err.Error() == "image not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if image.ProductID != product.ID {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+	// a product with no image URL has to keep at least one uploaded image
+	if product.ImageURL == "" && len(product.Images) <= 1 {
+		a.failedValidationResponse(w, r, map[string]string{"image_url": "must be set before the product's last uploaded image is deleted"})
+		return
+	}
+
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		err := tx.productImageModel.Delete(image)
+		if err != nil {
+			return err
+		}
+		product.Images, err = tx.productImageModel.ForProduct(product.ID)
+		if err != nil {
+			return err
+		}
+		return tx.publish(data.EventProductUpdated, product)
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	a.deleteFiles(r, image.Keys()...)
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "image successfully deleted"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
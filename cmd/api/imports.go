@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
+)
+
+// maxImportBytes caps the size of an import upload. Imports bypass
+// readJSON, which is limited to a single small JSON document.
+const maxImportBytes = 32 << 20
+
+// importTimeout is how long an import may take to upload and process
+const importTimeout = 5 * time.Minute
+
+// importRow is one product as it appears in an import file. An empty
+// currency or availability takes the same default as a new product.
+type importRow struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Category     string `json:"category"`
+	ImageURL     string `json:"image_url"`
+	ExternalID   string `json:"external_id"`
+	Price        int64  `json:"price"`
+	Currency     string `json:"currency"`
+	Availability string `json:"availability"`
+}
+
+// importRowError explains why a single row of the file was rejected
+type importRowError struct {
+	Row        int               `json:"row"`
+	ExternalID string            `json:"external_id,omitempty"`
+	Errors     map[string]string `json:"errors"`
+}
+
+// importReport summarises the outcome of an import
+type importReport struct {
+	Mode      string           `json:"mode"`
+	DryRun    bool             `json:"dry_run"`
+	TotalRows int              `json:"total_rows"`
+	ValidRows int              `json:"valid_rows"`
+	Inserted  int              `json:"inserted"`
+	Updated   int              `json:"updated"`
+	Failed    []importRowError `json:"failed"`
+}
+
+// readImportRows parses a CSV or NDJSON body and calls fn for every row.
+// Rows that cannot be parsed are passed to fn with a non-nil error; the
+// returned error is reserved for problems with the file as a whole.
+func readImportRows(body io.Reader, format string, fn func(row int, input importRow, err error)) error {
+	if format == formatCSV {
+		return readImportCSV(body, fn)
+	}
+	return readImportNDJSON(body, fn)
+}
+
+func readImportCSV(body io.Reader, fn func(int, importRow, error)) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return errors.New("the body must not be empty")
+	}
+	if err != nil {
+		return fmt.Errorf("the CSV header could not be read: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "name", "description", "category", "image_url", "external_id", "price", "currency", "availability":
+			columns[name] = i
+		default:
+			return fmt.Errorf("the CSV header contains unknown column %q", name)
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		return errors.New("the CSV header must contain a name column")
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			fn(row, importRow{}, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) != len(header) {
+			fn(row, importRow{}, fmt.Errorf("expected %d fields, got %d", len(header), len(record)))
+			continue
+		}
+
+		var price int64
+		if s := field(record, "price"); s != "" {
+			price, err = strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				fn(row, importRow{}, errors.New("price must be a whole number of minor units"))
+				continue
+			}
+		}
+
+		fn(row, importRow{
+			Name:         field(record, "name"),
+			Description:  field(record, "description"),
+			Category:     field(record, "category"),
+			ImageURL:     field(record, "image_url"),
+			ExternalID:   field(record, "external_id"),
+			Price:        price,
+			Currency:     field(record, "currency"),
+			Availability: field(record, "availability"),
+		}, nil)
+	}
+}
+
+func readImportNDJSON(body io.Reader, fn func(int, importRow, error)) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	row := 0
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		row++
+
+		var input importRow
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&input)
+		if err != nil {
+			fn(row, importRow{}, fmt.Errorf("the line contains invalid JSON: %w", err))
+			continue
+		}
+		fn(row, input, nil)
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return err
+	}
+	if row == 0 {
+		return errors.New("the body must not be empty")
+	}
+	return nil
+}
+
+func (a *applicationDependencies) importProductsHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+	var format string
+	switch mediaType {
+	case "text/csv":
+		format = formatCSV
+	case "application/x-ndjson", "application/ndjson":
+		format = formatNDJSON
+	default:
+		a.unsupportedMediaTypeResponse(w, r, "text/csv, application/x-ndjson")
+		return
+	}
+
+	report := importReport{
+		Mode:   r.URL.Query().Get("mode"),
+		DryRun: r.URL.Query().Get("dry_run") == "true",
+		Failed: []importRowError{},
+	}
+	if report.Mode == "" {
+		report.Mode = "insert"
+	}
+
+	v := validator.New()
+	v.Check(report.Mode == "insert" || report.Mode == "upsert", "mode", "must be insert or upsert")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	upsert := report.Mode == "upsert"
+	userID := a.contextGetUser(r).ID
+
+	var products []*data.Product
+	var productRows []int
+	seenExternalIDs := make(map[string]int)
+
+	// large uploads need longer than the server-wide read and write timeouts
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(importTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		a.logger.Warn("could not extend read deadline for import", "error", err.Error())
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		a.logger.Warn("could not extend write deadline for import", "error", err.Error())
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	err = readImportRows(r.Body, format, func(row int, input importRow, err error) {
+		report.TotalRows++
+		if err != nil {
+			report.Failed = append(report.Failed, importRowError{
+				Row:    row,
+				Errors: map[string]string{"row": err.Error()},
+			})
+			return
+		}
+
+		product := &data.Product{
+			Name:         input.Name,
+			Description:  input.Description,
+			Category:     input.Category,
+			ImageURL:     input.ImageURL,
+			ExternalID:   input.ExternalID,
+			Price:        input.Price,
+			Currency:     input.Currency,
+			Availability: input.Availability,
+		}
+
+		v := validator.New()
+		data.ValidateProduct(v, product)
+		if upsert {
+			v.Check(product.ExternalID != "", "external_id", "must be provided in upsert mode")
+		}
+		if product.ExternalID != "" {
+			first, seen := seenExternalIDs[product.ExternalID]
+			v.Check(!seen, "external_id", fmt.Sprintf("duplicates row %d", first))
+			if !seen {
+				seenExternalIDs[product.ExternalID] = row
+			}
+		}
+		if !v.IsEmpty() {
+			report.Failed = append(report.Failed, importRowError{
+				Row:        row,
+				ExternalID: product.ExternalID,
+				Errors:     v.Errors,
+			})
+			return
+		}
+
+		products = append(products, product)
+		productRows = append(productRows, row)
+	})
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			err = fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
+		}
+		a.badRequestResponse(w, r, err)
+		return
+	}
+	// in insert mode a row whose external_id is taken would fail the whole
+	// import, so find those rows first and report only them. Upserts
+	// don't update deleted products; they have to be restored first.
+	var externalIDs []string
+	for _, product := range products {
+		if product.ExternalID != "" {
+			externalIDs = append(externalIDs, product.ExternalID)
+		}
+	}
+	existing, err := a.productModel.ExistingExternalIDs(externalIDs)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	kept, keptRows := products[:0], productRows[:0]
+	for i, product := range products {
+		deleted, taken := existing[product.ExternalID]
+		var message string
+		switch {
+		case taken && deleted:
+			message = "belongs to a deleted product; restore it before importing"
+		case taken && !upsert:
+			message = "already exists; use mode=upsert to update it"
+		default:
+			kept = append(kept, product)
+			keptRows = append(keptRows, productRows[i])
+			continue
+		}
+		report.Failed = append(report.Failed, importRowError{
+			Row:        productRows[i],
+			ExternalID: product.ExternalID,
+			Errors:     map[string]string{"external_id": message},
+		})
+	}
+	products, productRows = kept, keptRows
+	report.ValidRows = len(products)
+
+	// every batch goes in one transaction, so an import is applied
+	// completely or not at all
+	if !report.DryRun && len(products) > 0 {
+		var inserted, updated int
+		err := a.atomically(r.Context(), func(tx *applicationDependencies) error {
+			for start := 0; start < len(products); start += data.ImportBatchSize {
+				end := min(start+data.ImportBatchSize, len(products))
+				batchInserted, batchUpdated, err := tx.productModel.BulkInsert(products[start:end], upsert, userID)
+				if err != nil {
+					return err
+				}
+				inserted += batchInserted
+				updated += batchUpdated
+			}
+			return tx.publish(data.EventProductsImported, envelope{"inserted": inserted, "updated": updated})
+		})
+		if err != nil {
+			var pqError *pq.Error
+			if !errors.As(err, &pqError) || pqError.Code != "23505" {
+				a.serverErrorResponse(w, r, err)
+				return
+			}
+			// another request took one of the external_ids after they were
+			// checked; nothing was written
+			for i, product := range products {
+				report.Failed = append(report.Failed, importRowError{
+					Row:        productRows[i],
+					ExternalID: product.ExternalID,
+					Errors:     map[string]string{"import": "rejected because an external_id was taken while the import ran; try again"},
+				})
+			}
+		} else {
+			report.Inserted = inserted
+			report.Updated = updated
+		}
+	}
+
+	sort.SliceStable(report.Failed, func(i, j int) bool {
+		return report.Failed[i].Row < report.Failed[j].Row
+	})
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"import": report}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/screening"
+	"github.com/RayMC17/AWT_Test1/internal/storage"
 	_ "github.com/lib/pq"
 )
 
@@ -25,14 +27,64 @@ type serverConfig struct {
 		burst   int     // initial requests possible
 		enabled bool    // enable or disable rate limiter
 	}
+	grpc struct {
+		port int // zero disables the gRPC server
+	}
+	idempotency struct {
+		ttl time.Duration // how long a stored response can be replayed
+	}
+	auth struct {
+		tokenTTL time.Duration // lifetime of authentication tokens
+	}
+	events struct {
+		retention time.Duration // how long review events are kept for stream resume
+	}
+	screening struct {
+		config string // JSON file configuring the review screening rules
+	}
+	reports struct {
+		threshold int // open reports that hide a review, zero disables
+	}
+	deleted struct {
+		retention time.Duration // how long soft-deleted records can be restored, zero keeps them forever
+	}
+	graphql struct {
+		maxDepth int // deepest nesting a query may have, zero disables
+		maxCost  int // highest estimated cost a query may have, zero disables
+	}
+	media struct {
+		dir       string // directory uploaded files are stored in
+		maxBytes  int64  // largest file accepted
+		maxFiles  int    // most attachments a review can have
+		maxImages int    // most images a product can have
+	}
 }
 
 type applicationDependencies struct {
-	config serverConfig
-	logger *slog.Logger
-	//userModel    data.UserModel
-	productModel data.ProductModel // Added productModel
-	reviewModel  data.ReviewModel  // Added reviewModel
+	config              serverConfig
+	logger              *slog.Logger
+	db                  *sql.DB
+	tx                  *sql.Tx // set when the models below are bound to a transaction
+	userModel           data.UserModel
+	tokenModel          data.TokenModel
+	permissionModel     data.PermissionModel
+	productModel        data.ProductModel // Added productModel
+	reviewModel         data.ReviewModel  // Added reviewModel
+	reportModel         data.ReportModel
+	reviewResponseModel data.ReviewResponseModel
+	reviewMediaModel    data.ReviewMediaModel
+	productImageModel   data.ProductImageModel
+	variantModel        data.VariantModel
+	revisionModel       data.RevisionModel
+	idempotencyModel    data.IdempotencyModel
+	reviewEventModel    data.ReviewEventModel
+	reviewEvents        *eventHub
+	outboxModel         data.OutboxModel
+	webhookModel        data.WebhookModel
+	webhooks            *webhookDispatcher
+	limiter             *clientLimiter
+	screener            *screening.Pipeline
+	store               storage.Store
 }
 
 func main() {
@@ -44,10 +96,35 @@ func main() {
 	flag.Float64Var(&settings.limiter.rps, "limiter-rps", 2, "Rate Limiter maximum requests per second")
 	flag.IntVar(&settings.limiter.burst, "limiter-burst", 5, "Rate Limiter maximum burst")
 	flag.BoolVar(&settings.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	flag.IntVar(&settings.grpc.port, "grpc-port", 4001, "gRPC server port (0 disables)")
+	flag.DurationVar(&settings.idempotency.ttl, "idempotency-ttl", 24*time.Hour, "How long Idempotency-Key responses are kept")
+	flag.DurationVar(&settings.auth.tokenTTL, "token-ttl", 24*time.Hour, "Lifetime of authentication tokens")
+	flag.DurationVar(&settings.events.retention, "event-retention", 7*24*time.Hour, "How long review events are kept for stream resume")
+	flag.StringVar(&settings.screening.config, "screening-config", "", "Path to a JSON file configuring review screening rules (defaults if empty)")
+	flag.IntVar(&settings.reports.threshold, "report-threshold", 5, "Open reports that automatically hide a review (0 disables)")
+	flag.DurationVar(&settings.deleted.retention, "purge-after", 30*24*time.Hour, "How long deleted products and reviews can be restored before they are purged (0 keeps them)")
+	flag.IntVar(&settings.graphql.maxDepth, "graphql-max-depth", 6, "Deepest nesting a GraphQL query may have (0 disables)")
+	flag.IntVar(&settings.graphql.maxCost, "graphql-max-cost", 10000, "Highest estimated cost of a GraphQL query, counting each field once per item of the lists it is in (0 disables)")
+	flag.StringVar(&settings.media.dir, "media-dir", "uploads", "Directory uploaded images are stored in")
+	flag.Int64Var(&settings.media.maxBytes, "media-max-bytes", 5<<20, "Largest image file accepted")
+	flag.IntVar(&settings.media.maxFiles, "media-max-files", 4, "Most images a review can have")
+	flag.IntVar(&settings.media.maxImages, "product-max-images", 10, "Most images a product can have")
 	flag.Parse()
 
 	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
 
+	screeningConfig, err := screening.LoadConfig(settings.screening.config)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	store, err := storage.NewDisk(settings.media.dir, "/v1/media")
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
 	db, err := openDB(settings)
 	if err != nil {
 		logger.Error(err.Error())
@@ -57,12 +134,30 @@ func main() {
 	logger.Info("database connection pool established")
 
 	appInstance := &applicationDependencies{
-		config: settings,
-		logger: logger,
-		//userModel:    data.UserModel{DB: db},
-		productModel: data.ProductModel{DB: db}, // Initialize productModel
-		reviewModel:  data.ReviewModel{DB: db},  // Initialize reviewModel
+		config:              settings,
+		logger:              logger,
+		db:                  db,
+		userModel:           data.UserModel{DB: db},
+		tokenModel:          data.TokenModel{DB: db},
+		permissionModel:     data.PermissionModel{DB: db},
+		productModel:        data.ProductModel{DB: db}, // Initialize productModel
+		reviewModel:         data.ReviewModel{DB: db},  // Initialize reviewModel
+		reportModel:         data.ReportModel{DB: db},
+		reviewResponseModel: data.ReviewResponseModel{DB: db},
+		reviewMediaModel:    data.ReviewMediaModel{DB: db},
+		productImageModel:   data.ProductImageModel{DB: db},
+		variantModel:        data.VariantModel{DB: db},
+		revisionModel:       data.RevisionModel{DB: db},
+		idempotencyModel:    data.IdempotencyModel{DB: db},
+		reviewEventModel:    data.ReviewEventModel{DB: db},
+		reviewEvents:        newEventHub(),
+		outboxModel:         data.OutboxModel{DB: db},
+		webhookModel:        data.WebhookModel{DB: db},
+		limiter:             newClientLimiter(settings.limiter.rps, settings.limiter.burst),
+		store:               store,
 	}
+	appInstance.webhooks = newWebhookDispatcher(appInstance)
+	appInstance.screener = screening.New(screeningConfig, appInstance.reviewModel)
 
 	//     apiServer := &http.Server{
 	//         Addr:         fmt.Sprintf(":%d", settings.port),
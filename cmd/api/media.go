@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/media"
+	"github.com/RayMC17/AWT_Test1/internal/storage"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// thumbnailSize is the longest side of a review image's thumbnail
+const thumbnailSize = 320
+
+// maxImagePixels bounds the dimensions of an uploaded image, which is
+// decoded in full before it is re-encoded
+const maxImagePixels = 40_000_000
+
+// uploadTimeout is how long an image upload may take
+const uploadTimeout = 2 * time.Minute
+
+// mediaUpload documents the multipart body of an image upload. Uploads
+// bypass readJSON, which is limited to a single small JSON document.
+type mediaUpload struct {
+	File []byte `json:"file"`
+}
+
+// readImages processes the "file" parts of a multipart upload, accepting
+// at most limit of them and making variants in sizes. Problems with the
+// files themselves are returned as validation errors, with tooMany
+// explaining the limit; the error is for a body that can't be read.
+func (a *applicationDependencies) readImages(r *http.Request, limit int, tooMany string, sizes []int) ([]*media.Image, map[string]string, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := media.Options{MaxBytes: a.config.media.maxBytes, MaxPixels: maxImagePixels, Sizes: sizes}
+	v := validator.New()
+	var images []*media.Image
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		if len(images) >= limit {
+			return nil, map[string]string{"file": tooMany}, nil
+		}
+
+		image, err := media.Process(part, opts)
+		var tooLarge *media.TooLargeError
+		switch {
+		case errors.As(err, &tooLarge):
+			v.AddError("file", fmt.Sprintf("%s: %s", part.FileName(), tooLarge.Message))
+		case errors.Is(err, media.ErrUnsupportedType):
+			v.AddError("file", fmt.Sprintf("%s: %s", part.FileName(), err.Error()))
+		case err != nil:
+			return nil, nil, err
+		}
+		if !v.IsEmpty() {
+			return nil, v.Errors, nil
+		}
+		images = append(images, image)
+	}
+
+	v.Check(len(images) > 0, "file", "must be provided")
+	if !v.IsEmpty() {
+		return nil, v.Errors, nil
+	}
+	return images, nil, nil
+}
+
+// readUploadError turns a failure to read an upload into a bad request
+func readUploadError(err error) error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return fmt.Errorf("the body must not be larger than %d bytes", maxBytesError.Limit)
+	}
+	return fmt.Errorf("the body must be multipart/form-data with a file field: %w", err)
+}
+
+// newStorageKey makes a random, unguessable key beneath dir
+func newStorageKey(dir string, ext string) (string, error) {
+	name := make([]byte, 16)
+	_, err := rand.Read(name)
+	if err != nil {
+		return "", err
+	}
+	return dir + "/" + hex.EncodeToString(name) + ext, nil
+}
+
+// storeImage saves an image and its variants, the variants under
+// data.VariantKey, and returns every key it stored
+func (a *applicationDependencies) storeImage(r *http.Request, key string, image *media.Image) ([]string, error) {
+	var keys []string
+	err := a.store.Put(r.Context(), key, image.ContentType, bytes.NewReader(image.Data))
+	if err != nil {
+		return keys, err
+	}
+	keys = append(keys, key)
+
+	for _, variant := range image.Variants {
+		variantKey := data.VariantKey(key, variant.Size)
+		err := a.store.Put(r.Context(), variantKey, image.ContentType, bytes.NewReader(variant.Data))
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, variantKey)
+	}
+	return keys, nil
+}
+
+// deleteFiles removes stored files, logging rather than failing because
+// the records pointing at them are already gone
+func (a *applicationDependencies) deleteFiles(r *http.Request, keys ...string) {
+	for _, key := range keys {
+		err := a.store.Delete(r.Context(), key)
+		if err != nil {
+			a.logger.Error("could not delete stored file", "key", key, "error", err.Error())
+		}
+	}
+}
+
+// extendDeadlines gives an upload longer than the server-wide read and write timeouts
+func (a *applicationDependencies) extendDeadlines(w http.ResponseWriter, timeout time.Duration) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(timeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		a.logger.Warn("could not extend read deadline for upload", "error", err.Error())
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		a.logger.Warn("could not extend write deadline for upload", "error", err.Error())
+	}
+}
+
+// uploadReviewMediaHandler attaches the images in a multipart/form-data
+// body to a review. Each image is checked, stripped of its metadata and
+// stored together with a thumbnail.
+func (a *applicationDependencies) uploadReviewMediaHandler(w http.ResponseWriter, r *http.Request) {
+	review := a.ownedReview(w, r, false)
+	if review == nil {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		a.unsupportedMediaTypeResponse(w, r, "multipart/form-data")
+		return
+	}
+
+	existing, err := a.reviewMediaModel.CountForReview(review.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	a.extendDeadlines(w, uploadTimeout)
+	r.Body = http.MaxBytesReader(w, r.Body, int64(a.config.media.maxFiles)*a.config.media.maxBytes+1<<20)
+	tooMany := fmt.Sprintf("a review can have at most %d images", a.config.media.maxFiles)
+	images, errs, err := a.readImages(r, a.config.media.maxFiles-existing, tooMany, []int{thumbnailSize})
+	if err != nil {
+		a.badRequestResponse(w, r, readUploadError(err))
+		return
+	}
+	if errs != nil {
+		a.failedValidationResponse(w, r, errs)
+		return
+	}
+
+	var keys []string
+	var attached []*data.ReviewMedia
+	dir := "reviews/" + strconv.FormatInt(review.ID, 10)
+	for _, image := range images {
+		key, err := newStorageKey(dir, image.Ext)
+		if err == nil {
+			var stored []string
+			stored, err = a.storeImage(r, key, image)
+			keys = append(keys, stored...)
+		}
+		if err != nil {
+			a.deleteFiles(r, keys...)
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		thumbnailKey := data.VariantKey(key, thumbnailSize)
+
+		attached = append(attached, &data.ReviewMedia{
+			ReviewID:     review.ID,
+			ContentType:  image.ContentType,
+			Width:        image.Width,
+			Height:       image.Height,
+			Size:         int64(len(image.Data)),
+			URL:          a.store.URL(key),
+			ThumbnailURL: a.store.URL(thumbnailKey),
+			Key:          key,
+			ThumbnailKey: thumbnailKey,
+		})
+	}
+
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		for _, m := range attached {
+			err := tx.reviewMediaModel.Insert(m)
+			if err != nil {
+				return err
+			}
+		}
+		review.Media = append(review.Media, attached...)
+		return tx.reviewChanged(data.EventReviewUpdated, review)
+	})
+	if err != nil {
+		a.deleteFiles(r, keys...)
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusCreated, envelope{"media": attached}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteReviewMediaHandler removes one of a review's images
+func (a *applicationDependencies) deleteReviewMediaHandler(w http.ResponseWriter, r *http.Request) {
+	review := a.ownedReview(w, r, true)
+	if review == nil {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("media_id"), 10, 64)
+	if err != nil || id < 1 {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	m, err := a.reviewMediaModel.Get(id)
+	if err != nil {
+		if err.Error() == "media not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	if m.ReviewID != review.ID {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		err := tx.reviewMediaModel.Delete(m.ID)
+		if err != nil {
+			return err
+		}
+		remaining := review.Media[:0]
+		for _, other := range review.Media {
+			if other.ID != m.ID {
+				remaining = append(remaining, other)
+			}
+		}
+		review.Media = remaining
+		return tx.reviewChanged(data.EventReviewUpdated, review)
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	a.deleteFiles(r, m.Key, m.ThumbnailKey)
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "media successfully deleted"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// serveMediaHandler sends a stored file. Keys are random and a stored file
+// never changes, so clients may cache it for as long as they like.
+func (a *applicationDependencies) serveMediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(httprouter.ParamsFromContext(r.Context()).ByName("key"), "/")
+
+	file, err := a.store.Open(r.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		a.logger.Error("could not send stored file", "key", key, "error", err.Error())
+	}
+}
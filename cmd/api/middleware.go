@@ -1,11 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
 	"golang.org/x/time/rate"
 )
 
@@ -22,28 +32,56 @@ func (a *applicationDependencies)recoverPanic(next http.Handler)http.Handler {
 	})
 }
 
-func (a *applicationDependencies) rateLimit(next http.Handler) http.Handler {
-	// Define a rate limiter struct
-	type client struct {
-		limiter  *rate.Limiter
-		lastSeen time.Time // remove map entries that are stale
-	}
-	var mu sync.Mutex                      // use to synchronize the map
-	var clients = make(map[string]*client) // the actual map
+// clientLimiter hands each client IP address its own token bucket. The
+// HTTP and gRPC servers share one, so a client gets the same budget
+// whichever it uses.
+type clientLimiter struct {
+	rps     float64
+	burst   int
+	mu      sync.Mutex
+	clients map[string]*limitedClient
+}
+
+type limitedClient struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time // remove map entries that are stale
+}
+
+func newClientLimiter(rps float64, burst int) *clientLimiter {
+	l := &clientLimiter{rps: rps, burst: burst, clients: make(map[string]*limitedClient)}
 	// A goroutine to remove stale entries from the map
 	go func() {
 		for {
 			time.Sleep(time.Minute)
-			mu.Lock() // begin cleanup
+			l.mu.Lock() // begin cleanup
 			// delete any entry not seen in three minutes
-			for ip, client := range clients {
+			for ip, client := range l.clients {
 				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
+					delete(l.clients, ip)
 				}
 			}
-			mu.Unlock() // finish clean up
+			l.mu.Unlock() // finish clean up
 		}
 	}()
+	return l
+}
+
+// allow reports whether the client at ip may make another request now
+func (l *clientLimiter) allow(ip string) bool {
+	l.mu.Lock() // exclusive access to the map
+	defer l.mu.Unlock()
+	// check if ip address already in map, if not add it
+	client, found := l.clients[ip]
+	if !found {
+		client = &limitedClient{limiter: rate.NewLimiter(rate.Limit(l.rps), l.burst)}
+		l.clients[ip] = client
+	}
+	// Update the last seem for the client
+	client.lastSeen = time.Now()
+	return client.limiter.Allow()
+}
+
+func (a *applicationDependencies) rateLimit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if a.config.limiter.enabled {
 			// get the IP address
@@ -53,28 +91,235 @@ func (a *applicationDependencies) rateLimit(next http.Handler) http.Handler {
 				return
 			}
 
-			mu.Lock() // exclusive access to the map
-			// check if ip address already in map, if not add it
-			_, found := clients[ip]
-			if !found {
-				clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(a.config.limiter.rps),a.config.limiter.burst),
-				}
-			}
-			// Update the last seem for the client
-			clients[ip].lastSeen = time.Now()
-
 			// Check the rate limit status
-			if !clients[ip].limiter.Allow() {
-				mu.Unlock() // no longer need exclusive access to the map
+			if !a.limiter.allow(ip) {
 				a.rateLimitExceededResponse(w, r)
 				return
 			}
+		}
+		next.ServeHTTP(w, r)
+	})
 
-			mu.Unlock()
-		} // others are free to get exclusive access to the map
+}
+
+ 
+// idempotencyRecorder passes a response through to the client while
+// keeping a copy so it can be replayed for a retried request
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// idempotent makes a POST handler safe to retry. The first request with a
+// given Idempotency-Key runs normally and its response is stored; a retry
+// with the same key and body gets the stored response back, and reusing
+// the key for a different request is rejected.
+//
+// Keys are scoped to the caller so one client can't replay or block
+// another's request by guessing its key. Anonymous callers can't be told
+// apart, so their keys are ignored and their requests always run.
+func (a *applicationDependencies) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keyValue := r.Header.Get("Idempotency-Key")
+		user := a.contextGetUser(r)
+		if keyValue == "" || user.IsAnonymous() {
+			next(w, r)
+			return
+		}
+		if len(keyValue) > 255 {
+			a.badRequestResponse(w, r, errors.New("the Idempotency-Key header must not be more than 255 characters"))
+			return
+		}
+
+		// read the body so it can be fingerprinted, then hand the handler a fresh copy
+		body, err := io.ReadAll(io.LimitReader(r.Body, 256_001))
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := strconv.FormatInt(user.ID, 10)
+		sum := sha256.Sum256([]byte(scope + "\n" + r.Method + " " + r.URL.Path + "\n" + string(body)))
+		key := &data.IdempotencyKey{
+			Key:         scope + ":" + keyValue,
+			Fingerprint: hex.EncodeToString(sum[:]),
+		}
+
+		err = a.idempotencyModel.Reserve(key, a.config.idempotency.ttl)
+		if errors.Is(err, data.ErrIdempotencyKeyExists) {
+			a.replayIdempotentResponse(w, r, key)
+			return
+		}
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+
+		rec := &idempotencyRecorder{ResponseWriter: w}
+
+		// deferred so the key is released even if the handler panics;
+		// server errors are not stored so the client can try again
+		defer func() {
+			var err error
+			if rec.status == 0 || rec.status >= 500 {
+				err = a.idempotencyModel.Delete(key.Key)
+			} else {
+				key.Status = rec.status
+				key.ContentType = rec.Header().Get("Content-Type")
+				key.Location = rec.Header().Get("Location")
+				key.Body = rec.body.Bytes()
+				err = a.idempotencyModel.Complete(key)
+			}
+			if err != nil {
+				a.logError(r, err)
+			}
+		}()
+
+		next(rec, r)
+	}
+}
+
+// replayIdempotentResponse answers a request whose key was already used
+func (a *applicationDependencies) replayIdempotentResponse(w http.ResponseWriter, r *http.Request, key *data.IdempotencyKey) {
+	stored, err := a.idempotencyModel.Get(key.Key)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	// expired or released between Reserve and Get
+	if stored == nil {
+		a.idempotencyInProgressResponse(w, r)
+		return
+	}
+
+	if stored.Fingerprint != key.Fingerprint {
+		a.idempotencyMismatchResponse(w, r)
+		return
+	}
+	if stored.Status == 0 {
+		a.idempotencyInProgressResponse(w, r)
+		return
+	}
+
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	if stored.Location != "" {
+		w.Header().Set("Location", stored.Location)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.Status)
+	_, err = w.Write(stored.Body)
+	if err != nil {
+		a.logError(r, err)
+	}
+}
+
+// authenticate reads a bearer token from the Authorization header and
+// puts the matching user in the request context. Requests without a token
+// carry data.AnonymousUser; a bad token is rejected outright.
+func (a *applicationDependencies) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			r = a.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			a.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+		token := headerParts[1]
+
+		v := validator.New()
+		data.ValidateTokenPlaintext(v, token)
+		if !v.IsEmpty() {
+			a.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		user, err := a.userModel.GetForToken(data.ScopeAuthentication, token)
+		if err != nil {
+			if err.Error() == "user not found" {
+				a.invalidAuthenticationTokenResponse(w, r)
+			} else {
+				a.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		r = a.contextSetUser(r, user)
 		next.ServeHTTP(w, r)
 	})
+}
 
+// requireAuthenticatedUser rejects anonymous requests
+func (a *applicationDependencies) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := a.contextGetUser(r)
+		if user.IsAnonymous() {
+			a.authenticationRequiredResponse(w, r)
+			return
+		}
+		next(w, r)
+	}
 }
 
- 
\ No newline at end of file
+// requirePermission rejects requests from users who have not been granted code
+func (a *applicationDependencies) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		permitted, err := a.hasPermission(r, code)
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return
+		}
+		if !permitted {
+			a.notPermittedResponse(w, r)
+			return
+		}
+		next(w, r)
+	}
+	return a.requireAuthenticatedUser(fn)
+}
+
+// hasPermission reports whether the request's user has been granted code,
+// for handlers that show more to privileged users rather than turning
+// everyone else away
+func (a *applicationDependencies) hasPermission(r *http.Request, code string) (bool, error) {
+	return a.userHasPermission(a.contextGetUser(r), code)
+}
+
+// userHasPermission reports whether user has been granted code
+func (a *applicationDependencies) userHasPermission(user *data.User, code string) (bool, error) {
+	if user.IsAnonymous() {
+		return false, nil
+	}
+
+	permissions, err := a.permissionModel.GetAllForUser(user.ID)
+	if err != nil {
+		return false, err
+	}
+	return permissions.Include(code), nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// moderationInput is the request body for a moderation decision
+type moderationInput struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// listModerationQueueHandler lists reviews in one status, oldest first.
+// The default status is pending, which is the moderators' work queue.
+func (a *applicationDependencies) listModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	status := qs.Get("status")
+	if status == "" {
+		status = data.ReviewPending
+	}
+	productID, _ := strconv.ParseInt(qs.Get("product_id"), 10, 64)
+	filters := data.Filters{
+		Limit:  parseInt(qs.Get("limit"), 10),
+		Offset: parseInt(qs.Get("offset"), 0),
+	}
+
+	v := validator.New()
+	v.Check(slices.Contains(data.ReviewStatuses, status), "status", "must be pending, approved, rejected or hidden")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	reviews, err := a.reviewModel.Queue(status, productID, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	views := make([]*data.ModerationView, len(reviews))
+	for i, review := range reviews {
+		views[i] = review.ForModeration()
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"reviews": views}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// moderateReviewHandler moves a review to a new status with a reason and
+// recalculates the product's rating, since only approved reviews count.
+func (a *applicationDependencies) moderateReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	review, err := a.reviewModel.Get(id)
+	if err != nil {
+		if err.Error() == "review not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input moderationInput
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateReviewTransition(v, review.Status, input.Status, input.Reason)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	review.Status = input.Status
+	review.ModerationReason = input.Reason
+	moderator := a.contextGetUser(r)
+
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		err := tx.reviewModel.Moderate(review, moderator.ID)
+		if err != nil {
+			return err
+		}
+		// the decision deals with every report made so far
+		err = tx.reportModel.Resolve(review.ID)
+		if err != nil {
+			return err
+		}
+		return tx.reviewChanged(data.EventReviewModerated, review)
+	})
+	if err != nil {
+		// approving a rejected review fails if its author has written another since
+		if errors.Is(err, data.ErrDuplicateReview) {
+			a.duplicateReviewResponse(w, r)
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"review": review.ForModeration()}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
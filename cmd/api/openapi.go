@@ -0,0 +1,671 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// apiParam documents a query parameter
+type apiParam struct {
+	name        string
+	description string
+	schema      map[string]any
+}
+
+// apiOperation documents one route. Request and response schemas are
+// generated from the Go values given here, so they follow the types the
+// handlers actually decode and encode.
+type apiOperation struct {
+	method    string
+	path      string // httprouter syntax, e.g. /v1/products/:id
+	summary   string
+	tag       string
+	query     []apiParam
+	body      any      // zero value of the request body type, nil for none
+	bodyTypes []string // request content types, application/json if empty
+	status    int      // success status code
+	statuses  []int    // further success statuses with the same body, e.g. 201 when a PUT creates
+	result    any      // success body, usually an envelope of zero values
+	formats   []string // success content types, application/json if empty
+	errors    []int    // error statuses the route can return
+	auth      string   // permission a bearer token must carry, "*" for any authenticated user
+}
+
+// listFormats are the representations served by the list and show endpoints
+var listFormats = []string{formatJSON, formatCSV, formatNDJSON, formatXML}
+
+// inputRules maps each request body type to the validation it goes
+// through. The spec runs the rules against a zero value and marks every
+// field they reject as required.
+var inputRules = map[reflect.Type]func(*validator.Validator){
+	reflect.TypeOf(productInput{}): func(v *validator.Validator) {
+		data.ValidateProduct(v, &data.Product{})
+	},
+	reflect.TypeOf(reviewInput{}): func(v *validator.Validator) {
+		data.ValidateReview(v, &data.Review{})
+	},
+	reflect.TypeOf(reportInput{}): func(v *validator.Validator) {
+		data.ValidateReport(v, &data.Report{})
+	},
+	reflect.TypeOf(responseInput{}): func(v *validator.Validator) {
+		data.ValidateReviewResponse(v, &data.ReviewResponse{})
+	},
+	reflect.TypeOf(variantInput{}): func(v *validator.Validator) {
+		data.ValidateVariant(v, &data.Variant{})
+	},
+}
+
+// inputTypes are the request body types fieldConstraints applies to
+var inputTypes = map[reflect.Type]bool{
+	reflect.TypeOf(productInput{}):       true,
+	reflect.TypeOf(productUpdateInput{}): true,
+	reflect.TypeOf(productPatch{}):       true,
+	reflect.TypeOf(reviewInput{}):        true,
+	reflect.TypeOf(reviewUpdateInput{}):  true,
+	reflect.TypeOf(reviewPatch{}):        true,
+	reflect.TypeOf(variantInput{}):       true,
+	reflect.TypeOf(variantUpdateInput{}): true,
+	reflect.TypeOf(importRow{}):          true,
+}
+
+// fieldConstraints carries the bounds the validators enforce on fields
+// shared by the input and patch types
+var fieldConstraints = map[string]map[string]any{
+	"name":         {"maxLength": 100},
+	"category":     {"maxLength": 50},
+	"external_id":  {"maxLength": 100},
+	"image_url":    {"maxLength": 2048, "format": "uri"},
+	"rating":       {"minimum": 1, "maximum": 5},
+	"sku":          {"maxLength": 64},
+	"price":        {"minimum": 0},
+	"currency":     {"pattern": "^[A-Z]{3}$"},
+	"availability": {"enum": data.ProductAvailabilities},
+}
+
+func sortParam(safelist []string) apiParam {
+	return apiParam{
+		name:        "sort",
+		description: "sort order",
+		schema:      map[string]any{"type": "string", "enum": safelist},
+	}
+}
+
+func pageParams() []apiParam {
+	return []apiParam{
+		{name: "limit", description: "page size (1-100)", schema: map[string]any{"type": "integer", "default": 10}},
+		{name: "offset", description: "number of rows to skip", schema: map[string]any{"type": "integer", "default": 0}},
+	}
+}
+
+// apiOperations lists every documented route
+func apiOperations() []apiOperation {
+	productFilters := append([]apiParam{
+		{name: "name", description: "case-insensitive substring match on the name", schema: map[string]any{"type": "string"}},
+		{name: "category", description: "exact category match", schema: map[string]any{"type": "string"}},
+		{name: "status", description: "active unless set; listing drafts needs products:edit", schema: map[string]any{"type": "string", "enum": data.ProductStatuses}},
+		{name: "min_price", description: "lowest price in minor units", schema: map[string]any{"type": "integer", "format": "int64", "minimum": 0}},
+		{name: "max_price", description: "highest price in minor units", schema: map[string]any{"type": "integer", "format": "int64", "minimum": 0}},
+		{name: "in_stock", description: "true for only in-stock products, false for only the rest", schema: map[string]any{"type": "boolean"}},
+		sortParam(data.ProductSortSafelist),
+	}, pageParams()...)
+	reviewFilters := append([]apiParam{
+		{name: "product_id", description: "only reviews for this product", schema: map[string]any{"type": "integer"}},
+		{name: "variant_id", description: "only reviews of this product variant", schema: map[string]any{"type": "integer"}},
+		sortParam(data.ReviewSortSafelist),
+	}, pageParams()...)
+	exportFormat := apiParam{name: "format", description: "overrides the Accept header", schema: map[string]any{"type": "string", "enum": []string{"csv", "ndjson"}}}
+	patchTypes := []string{formatJSON, mediaMergePatch, mediaJSONPatch}
+
+	return []apiOperation{
+		{method: http.MethodPost, path: "/v1/users", summary: "Register a user", tag: "users",
+			body: userInput{}, status: http.StatusCreated, result: envelope{"user": &data.User{}},
+			errors: []int{400, 422, 429, 500}},
+		{method: http.MethodPost, path: "/v1/tokens/authentication", summary: "Exchange an email and password for a bearer token", tag: "users",
+			body: credentialsInput{}, status: http.StatusCreated, result: envelope{"authentication_token": &data.Token{}},
+			errors: []int{400, 401, 422, 429, 500}},
+
+		{method: http.MethodPost, path: "/v1/products", summary: "Create a product", tag: "products",
+			body: productInput{}, status: http.StatusCreated, result: envelope{"product": &data.Product{}},
+			errors: []int{400, 401, 403, 409, 422, 429, 500}, auth: "products:edit"},
+		{method: http.MethodGet, path: "/v1/products", summary: "List products", tag: "products",
+			query: productFilters, status: http.StatusOK, result: envelope{"products": []*data.Product{}},
+			formats: listFormats, errors: []int{404, 406, 422, 429, 500}},
+		{method: http.MethodGet, path: "/v1/products/:id", summary: "Show a product", tag: "products",
+			status: http.StatusOK, result: envelope{"product": &data.Product{}},
+			formats: listFormats, errors: []int{404, 406, 429, 500}},
+		{method: http.MethodPatch, path: "/v1/products/:id", summary: "Update a product", tag: "products",
+			body: productUpdateInput{}, bodyTypes: patchTypes, status: http.StatusOK, result: envelope{"product": &data.Product{}},
+			errors: []int{400, 401, 403, 404, 409, 415, 422, 429, 500}, auth: "products:edit"},
+		{method: http.MethodDelete, path: "/v1/products/:id", summary: "Delete a product", tag: "products",
+			status: http.StatusOK, result: envelope{"message": ""}, errors: []int{401, 403, 404, 429, 500}, auth: "products:edit"},
+		{method: http.MethodGet, path: "/v1/products/:id/history", summary: "List a product's earlier versions, newest first", tag: "products",
+			query: pageParams(), status: http.StatusOK, result: envelope{"history": []*data.Revision{}},
+			errors: []int{404, 429, 500}},
+		{method: http.MethodPost, path: "/v1/products/:id/restore", summary: "Restore a deleted product and the reviews deleted with it", tag: "products",
+			status: http.StatusOK, result: envelope{"product": &data.Product{}},
+			errors: []int{401, 403, 404, 409, 429, 500}, auth: "catalog:restore"},
+		{method: http.MethodGet, path: "/v1/products/:id/images", summary: "List a product's images in order", tag: "products",
+			status: http.StatusOK, result: envelope{"images": []*data.ProductImage{}}, errors: []int{404, 429, 500}},
+		{method: http.MethodPost, path: "/v1/products/:id/images", summary: "Add images after a product's existing ones; repeat the file field for several", tag: "products",
+			body: mediaUpload{}, bodyTypes: []string{"multipart/form-data"}, status: http.StatusCreated, result: envelope{"images": []*data.ProductImage{}},
+			errors: []int{400, 401, 403, 404, 415, 422, 429, 500}, auth: "products:edit"},
+		{method: http.MethodPut, path: "/v1/products/:id/images/order", summary: "Rearrange a product's images", tag: "products",
+			body: imageOrderInput{}, status: http.StatusOK, result: envelope{"images": []*data.ProductImage{}},
+			errors: []int{400, 401, 403, 404, 422, 429, 500}, auth: "products:edit"},
+		{method: http.MethodDelete, path: "/v1/products/:id/images/:image_id", summary: "Delete a product image and its resized copies", tag: "products",
+			status: http.StatusOK, result: envelope{"message": ""}, errors: []int{401, 403, 404, 422, 429, 500}, auth: "products:edit"},
+		{method: http.MethodGet, path: "/v1/products/:id/variants", summary: "List a product's variants with their ratings", tag: "products",
+			status: http.StatusOK, result: envelope{"variants": []*data.Variant{}}, errors: []int{404, 429, 500}},
+		{method: http.MethodPost, path: "/v1/products/:id/variants", summary: "Add a variant to a product", tag: "products",
+			body: variantInput{}, status: http.StatusCreated, result: envelope{"variant": &data.Variant{}},
+			errors: []int{400, 401, 403, 404, 409, 422, 429, 500}, auth: "products:edit"},
+		{method: http.MethodGet, path: "/v1/products/:id/variants/:variant_id", summary: "Show a product variant and its rating", tag: "products",
+			status: http.StatusOK, result: envelope{"variant": &data.Variant{}}, errors: []int{404, 429, 500}},
+		{method: http.MethodPatch, path: "/v1/products/:id/variants/:variant_id", summary: "Update a product variant", tag: "products",
+			body: variantUpdateInput{}, bodyTypes: patchTypes, status: http.StatusOK, result: envelope{"variant": &data.Variant{}},
+			errors: []int{400, 401, 403, 404, 409, 415, 422, 429, 500}, auth: "products:edit"},
+		{method: http.MethodDelete, path: "/v1/products/:id/variants/:variant_id", summary: "Delete a product variant; its reviews stay on the product", tag: "products",
+			status: http.StatusOK, result: envelope{"message": ""}, errors: []int{401, 403, 404, 429, 500}, auth: "products:edit"},
+
+		{method: http.MethodPost, path: "/v1/products/:id/reviews", summary: "Create a review", tag: "reviews",
+			body: reviewInput{}, status: http.StatusCreated, result: envelope{"review": &data.Review{}},
+			errors: []int{400, 404, 409, 422, 429, 500}},
+		{method: http.MethodPut, path: "/v1/products/:id/reviews/mine", summary: "Create or replace the caller's review of a product; 201 when it is created", tag: "reviews",
+			body: reviewInput{}, status: http.StatusOK, statuses: []int{http.StatusCreated}, result: envelope{"review": &data.Review{}},
+			errors: []int{400, 401, 404, 409, 422, 429, 500}, auth: "*"},
+		{method: http.MethodGet, path: "/v1/products/:id/reviews", summary: "List a product's reviews", tag: "reviews",
+			query: reviewFilters[1:], status: http.StatusOK, result: envelope{"reviews": []*data.Review{}},
+			formats: listFormats, errors: []int{404, 406, 422, 429, 500}},
+		{method: http.MethodGet, path: "/v1/reviews", summary: "List reviews", tag: "reviews",
+			query: reviewFilters, status: http.StatusOK, result: envelope{"reviews": []*data.Review{}},
+			formats: listFormats, errors: []int{404, 406, 422, 429, 500}},
+		{method: http.MethodGet, path: "/v1/products/:id/reviews/:review_id", summary: "Show a review", tag: "reviews",
+			status: http.StatusOK, result: envelope{"review": &data.Review{}},
+			formats: listFormats, errors: []int{404, 406, 429, 500}},
+		{method: http.MethodPatch, path: "/v1/products/:id/reviews/:review_id", summary: "Update your review; moderators can update any", tag: "reviews",
+			body: reviewUpdateInput{}, bodyTypes: patchTypes, status: http.StatusOK, result: envelope{"review": &data.Review{}},
+			errors: []int{400, 401, 403, 404, 409, 415, 422, 429, 500}, auth: "*"},
+		{method: http.MethodDelete, path: "/v1/products/:id/reviews/:review_id", summary: "Delete your review; moderators can delete any", tag: "reviews",
+			status: http.StatusOK, result: envelope{"message": ""}, errors: []int{401, 403, 404, 429, 500}, auth: "*"},
+		{method: http.MethodPost, path: "/v1/products/:id/reviews/:review_id/helpful", summary: "Mark a review as helpful; each user's vote counts once", tag: "reviews",
+			status: http.StatusOK, result: envelope{"review": &data.Review{}}, errors: []int{401, 404, 429, 500}, auth: "*"},
+		{method: http.MethodPost, path: "/v1/products/:id/reviews/:review_id/reports", summary: "Report a review as abusive", tag: "reviews",
+			body: reportInput{}, status: http.StatusCreated, result: envelope{"report": &data.Report{}},
+			errors: []int{400, 401, 404, 409, 422, 429, 500}, auth: "*"},
+		{method: http.MethodGet, path: "/v1/products/:id/reviews/:review_id/history", summary: "List a review's earlier versions, newest first", tag: "reviews",
+			query: pageParams(), status: http.StatusOK, result: envelope{"history": []*data.Revision{}},
+			errors: []int{401, 403, 404, 429, 500}, auth: "reviews:moderate"},
+		{method: http.MethodPost, path: "/v1/products/:id/reviews/:review_id/restore", summary: "Restore a deleted review", tag: "reviews",
+			status: http.StatusOK, result: envelope{"review": &data.Review{}},
+			errors: []int{401, 403, 404, 409, 429, 500}, auth: "catalog:restore"},
+		{method: http.MethodGet, path: "/v1/products/:id/reviews/:review_id/responses", summary: "Show the official response to a review", tag: "reviews",
+			status: http.StatusOK, result: envelope{"response": &data.ReviewResponse{}}, errors: []int{404, 429, 500}},
+		{method: http.MethodPost, path: "/v1/products/:id/reviews/:review_id/responses", summary: "Respond to a review on the seller's behalf", tag: "reviews",
+			body: responseInput{}, status: http.StatusCreated, result: envelope{"response": &data.ReviewResponse{}},
+			errors: []int{400, 401, 403, 404, 409, 422, 429, 500}, auth: "products:respond"},
+		{method: http.MethodPatch, path: "/v1/products/:id/reviews/:review_id/responses", summary: "Rewrite the official response to a review", tag: "reviews",
+			body: responseInput{}, status: http.StatusOK, result: envelope{"response": &data.ReviewResponse{}},
+			errors: []int{400, 401, 403, 404, 422, 429, 500}, auth: "products:respond"},
+		{method: http.MethodDelete, path: "/v1/products/:id/reviews/:review_id/responses", summary: "Delete the official response to a review", tag: "reviews",
+			status: http.StatusOK, result: envelope{"message": ""}, errors: []int{401, 403, 404, 429, 500}, auth: "products:respond"},
+		{method: http.MethodPost, path: "/v1/products/:id/reviews/:review_id/media", summary: "Attach images to your review; repeat the file field for several", tag: "reviews",
+			body: mediaUpload{}, bodyTypes: []string{"multipart/form-data"}, status: http.StatusCreated, result: envelope{"media": []*data.ReviewMedia{}},
+			errors: []int{400, 401, 403, 404, 415, 422, 429, 500}, auth: "*"},
+		{method: http.MethodDelete, path: "/v1/products/:id/reviews/:review_id/media/:media_id", summary: "Remove an image from your review; moderators can remove any", tag: "reviews",
+			status: http.StatusOK, result: envelope{"message": ""}, errors: []int{401, 403, 404, 429, 500}, auth: "*"},
+		{method: http.MethodGet, path: "/v1/media/*key", summary: "Fetch an uploaded image or one of its resized copies", tag: "media",
+			status: http.StatusOK, result: []byte{}, formats: []string{"image/jpeg", "image/png"}, errors: []int{404, 429, 500}},
+
+		{method: http.MethodGet, path: "/v1/exports/products", summary: "Stream every matching product", tag: "exports",
+			query: append(productFilters[:7:7], exportFormat), status: http.StatusOK, result: data.Product{},
+			formats: []string{formatNDJSON, formatCSV}, errors: []int{406, 422, 429}},
+		{method: http.MethodGet, path: "/v1/exports/reviews", summary: "Stream every matching review", tag: "exports",
+			query: append(reviewFilters[:1:1], sortParam(data.ReviewSortSafelist), exportFormat), status: http.StatusOK, result: data.Review{},
+			formats: []string{formatNDJSON, formatCSV}, errors: []int{406, 422, 429}},
+		{method: http.MethodGet, path: "/v1/moderation/reviews", summary: "List reviews awaiting moderation, oldest first", tag: "moderation",
+			query: append([]apiParam{
+				{name: "status", description: "queue to list", schema: map[string]any{"type": "string", "enum": data.ReviewStatuses, "default": data.ReviewPending}},
+				{name: "product_id", description: "only reviews for this product", schema: map[string]any{"type": "integer"}},
+			}, pageParams()...),
+			status: http.StatusOK, result: envelope{"reviews": []*data.ModerationView{}},
+			errors: []int{401, 403, 422, 429, 500}, auth: "reviews:moderate"},
+		{method: http.MethodPost, path: "/v1/moderation/reviews/:id/transition", summary: "Approve, reject or hide a review", tag: "moderation",
+			body: moderationInput{}, status: http.StatusOK, result: envelope{"review": &data.ModerationView{}},
+			errors: []int{400, 401, 403, 404, 422, 429, 500}, auth: "reviews:moderate"},
+		{method: http.MethodGet, path: "/v1/moderation/reports", summary: "List open reports grouped by review, most reported first", tag: "moderation",
+			query: append([]apiParam{
+				{name: "status", description: "only reviews in this status", schema: map[string]any{"type": "string", "enum": data.ReviewStatuses}},
+			}, pageParams()...),
+			status: http.StatusOK, result: envelope{"reports": []*data.ReportSummary{}},
+			errors: []int{401, 403, 422, 429, 500}, auth: "reviews:moderate"},
+		{method: http.MethodGet, path: "/v1/moderation/reviews/:id/reports", summary: "List every report against a review", tag: "moderation",
+			status: http.StatusOK, result: envelope{"reports": []*data.Report{}},
+			errors: []int{401, 403, 404, 429, 500}, auth: "reviews:moderate"},
+
+		{method: http.MethodGet, path: "/v1/stream/reviews", summary: "Stream review activity as Server-Sent Events", tag: "stream",
+			query: []apiParam{
+				{name: "product_id", description: "only events for this product", schema: map[string]any{"type": "integer"}},
+				{name: "last_event_id", description: "resume after this event; the Last-Event-ID header takes precedence", schema: map[string]any{"type": "integer"}},
+			},
+			status: http.StatusOK, result: data.ReviewEvent{}, formats: []string{"text/event-stream"}, errors: []int{422, 429, 500}},
+		{method: http.MethodPost, path: "/v1/imports/products", summary: "Bulk import products", tag: "imports",
+			query: []apiParam{
+				{name: "mode", description: "upsert matches existing products by external_id; deleted products are not updated", schema: map[string]any{"type": "string", "enum": []string{"insert", "upsert"}, "default": "insert"}},
+				{name: "dry_run", description: "validate without writing", schema: map[string]any{"type": "boolean"}},
+			},
+			body: importRow{}, bodyTypes: []string{formatCSV, formatNDJSON}, status: http.StatusOK,
+			result: envelope{"import": importReport{}}, errors: []int{400, 401, 403, 415, 422, 429, 500}, auth: "products:edit"},
+
+		{method: http.MethodPost, path: "/v1/webhooks", summary: "Subscribe a URL to events; the signing secret is only returned here", tag: "webhooks",
+			body: webhookInput{}, status: http.StatusCreated, result: envelope{"webhook": &data.Webhook{}},
+			errors: []int{400, 401, 403, 422, 429, 500}, auth: "webhooks:manage"},
+		{method: http.MethodGet, path: "/v1/webhooks", summary: "List webhooks", tag: "webhooks",
+			query: pageParams(), status: http.StatusOK, result: envelope{"webhooks": []*data.Webhook{}},
+			errors: []int{401, 403, 429, 500}, auth: "webhooks:manage"},
+		{method: http.MethodGet, path: "/v1/webhooks/:id", summary: "Show a webhook", tag: "webhooks",
+			status: http.StatusOK, result: envelope{"webhook": &data.Webhook{}}, errors: []int{401, 403, 404, 429, 500}, auth: "webhooks:manage"},
+		{method: http.MethodPatch, path: "/v1/webhooks/:id", summary: "Update a webhook", tag: "webhooks",
+			body: webhookUpdateInput{}, status: http.StatusOK, result: envelope{"webhook": &data.Webhook{}},
+			errors: []int{400, 401, 403, 404, 422, 429, 500}, auth: "webhooks:manage"},
+		{method: http.MethodDelete, path: "/v1/webhooks/:id", summary: "Delete a webhook", tag: "webhooks",
+			status: http.StatusOK, result: envelope{"message": ""}, errors: []int{401, 403, 404, 429, 500}, auth: "webhooks:manage"},
+		{method: http.MethodGet, path: "/v1/webhooks/:id/deliveries", summary: "List a webhook's deliveries; status=dead is the dead-letter view", tag: "webhooks",
+			query: append([]apiParam{
+				{name: "status", description: "only deliveries in this state", schema: map[string]any{"type": "string", "enum": data.DeliveryStatuses}},
+			}, pageParams()...),
+			status: http.StatusOK, result: envelope{"deliveries": []*data.WebhookDelivery{}}, errors: []int{401, 403, 404, 422, 429, 500}, auth: "webhooks:manage"},
+		{method: http.MethodPost, path: "/v1/webhooks/:id/deliveries/:delivery_id/retry", summary: "Requeue a delivery", tag: "webhooks",
+			status: http.StatusAccepted, result: envelope{"delivery": &data.WebhookDelivery{}}, errors: []int{401, 403, 404, 429, 500}, auth: "webhooks:manage"},
+
+		{method: http.MethodPost, path: "/v1/batch", summary: "Run several operations in one request", tag: "batch",
+			body: batchInput{}, status: http.StatusOK, result: envelope{"committed": false, "results": []batchResult{}},
+			errors: []int{400, 422, 429, 500}},
+
+		{method: http.MethodPost, path: "/v1/graphql", summary: "Run a GraphQL query over products, reviews and categories", tag: "graphql",
+			body: graphQLRequest{}, status: http.StatusOK, result: map[string]any{"data": map[string]any{}, "errors": []any{}},
+			errors: []int{400, 422, 429, 500}},
+		{method: http.MethodGet, path: "/v1/graphql", summary: "Run a GraphQL query given in the query string", tag: "graphql",
+			query: []apiParam{
+				{name: "query", description: "the GraphQL query", schema: map[string]any{"type": "string"}},
+				{name: "operationName", description: "the operation to run when the query has several", schema: map[string]any{"type": "string"}},
+			},
+			status: http.StatusOK, result: map[string]any{"data": map[string]any{}, "errors": []any{}},
+			errors: []int{422, 429, 500}},
+
+		{method: http.MethodGet, path: "/v1/openapi.json", summary: "This document", tag: "meta",
+			status: http.StatusOK, result: map[string]any{}},
+		{method: http.MethodGet, path: "/v1/docs", summary: "A page that renders this document", tag: "meta",
+			status: http.StatusOK, result: "", formats: []string{"text/html"}},
+	}
+}
+
+// specBuilder turns Go types into OpenAPI schemas, collecting named
+// struct types as reusable components
+type specBuilder struct {
+	components map[string]any
+}
+
+// componentName exports a Go type name, e.g. importReport -> ImportReport
+func componentName(t reflect.Type) string {
+	runes := []rune(t.Name())
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func (b *specBuilder) schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		return map[string]any{}
+	case reflect.TypeOf([]byte{}):
+		return map[string]any{"type": "string", "format": "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]any{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]any{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := b.components[name]; !ok {
+			// reserve the name first so self-referencing types terminate
+			b.components[name] = map[string]any{}
+			b.components[name] = b.structSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+
+	return map[string]any{}
+}
+
+func (b *specBuilder) structSchema(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// an untagged embedded struct's fields are promoted, as
+		// encoding/json does
+		if name == "" && field.Anonymous {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for name, schema := range b.structSchema(embedded)["properties"].(map[string]any) {
+					properties[name] = schema
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := b.schemaFor(field.Type)
+		if _, isRef := schema["$ref"]; !isRef && inputTypes[t] {
+			for key, value := range fieldConstraints[name] {
+				schema[key] = value
+			}
+		}
+		properties[name] = schema
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+
+	if rules, ok := inputRules[t]; ok {
+		v := validator.New()
+		rules(v)
+		var required []string
+		for name, message := range v.Errors {
+			if property, ok := properties[name].(map[string]any); ok {
+				property["description"] = message
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// valueSchema describes a response value. Envelopes and other maps are
+// described member by member from the zero values they hold.
+func (b *specBuilder) valueSchema(value any) map[string]any {
+	var members map[string]any
+	switch m := value.(type) {
+	case envelope:
+		members = m
+	case map[string]any:
+		if len(m) == 0 {
+			return map[string]any{"type": "object"}
+		}
+		members = m
+	default:
+		return b.schemaFor(reflect.TypeOf(value))
+	}
+
+	properties := make(map[string]any)
+	for key, member := range members {
+		properties[key] = b.schemaFor(reflect.TypeOf(member))
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func content(types []string, schema map[string]any) map[string]any {
+	if len(types) == 0 {
+		types = []string{formatJSON}
+	}
+	c := make(map[string]any)
+	for _, t := range types {
+		c[t] = map[string]any{"schema": schema}
+	}
+	return c
+}
+
+// openAPIPath converts httprouter parameters to OpenAPI templates and
+// returns the parameter names in order
+func openAPIPath(path string) (string, []string) {
+	var names []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), names
+}
+
+// routeTable is an httprouter.Router that remembers the routes registered
+// on it, which httprouter itself can't list, so the document can be
+// checked against them
+type routeTable struct {
+	*httprouter.Router
+	routes []apiRoute
+}
+
+// apiRoute is a registered method and path in httprouter syntax
+type apiRoute struct {
+	method string
+	path   string
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{Router: httprouter.New()}
+}
+
+// HandlerFunc registers handler for method and path and records the route
+func (t *routeTable) HandlerFunc(method string, path string, handler http.HandlerFunc) {
+	t.routes = append(t.routes, apiRoute{method: method, path: path})
+	t.Router.HandlerFunc(method, path, handler)
+}
+
+// undocumentedRoutes lists the routes registered on router that
+// apiOperations leaves out
+func undocumentedRoutes(router *routeTable) []apiRoute {
+	documented := make(map[apiRoute]bool)
+	for _, op := range apiOperations() {
+		documented[apiRoute{method: op.method, path: op.path}] = true
+	}
+
+	var missing []apiRoute
+	for _, route := range router.routes {
+		if !documented[route] {
+			missing = append(missing, route)
+		}
+	}
+	return missing
+}
+
+// buildOpenAPISpec generates the document. Operations whose route is not
+// registered on router are left out and logged, so the document never
+// advertises an endpoint that does not exist. Registered routes with no
+// operation are logged too.
+func (a *applicationDependencies) buildOpenAPISpec(router *routeTable) map[string]any {
+	for _, route := range undocumentedRoutes(router) {
+		a.logger.Warn("registered route is not documented", "method", route.method, "path", route.path)
+	}
+
+	b := &specBuilder{components: make(map[string]any)}
+
+	problemSchema := b.schemaFor(reflect.TypeOf(problem{}))
+	legacySchema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"error": map[string]any{
+				"oneOf": []any{
+					map[string]any{"type": "string"},
+					map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}},
+				},
+			},
+		},
+	}
+	b.components["LegacyError"] = legacySchema
+
+	paths := make(map[string]any)
+	for _, op := range apiOperations() {
+		if handle, _, _ := router.Lookup(op.method, op.path); handle == nil {
+			a.logger.Warn("documented route is not registered", "method", op.method, "path", op.path)
+			continue
+		}
+
+		path, pathParams := openAPIPath(op.path)
+		var parameters []any
+		for _, name := range pathParams {
+			schema := map[string]any{"type": "integer", "format": "int64", "minimum": 1}
+			if strings.Contains(op.path, "/*"+name) {
+				schema = map[string]any{"type": "string"}
+			}
+			parameters = append(parameters, map[string]any{
+				"name": name, "in": "path", "required": true, "schema": schema,
+			})
+		}
+		for _, q := range op.query {
+			parameters = append(parameters, map[string]any{
+				"name": q.name, "in": "query", "description": q.description, "schema": q.schema,
+			})
+		}
+
+		responses := make(map[string]any)
+		for _, status := range append([]int{op.status}, op.statuses...) {
+			responses[strconv.Itoa(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content":     content(op.formats, b.valueSchema(op.result)),
+			}
+		}
+		for _, status := range op.errors {
+			responses[strconv.Itoa(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content": map[string]any{
+					"application/problem+json": map[string]any{"schema": problemSchema},
+					"application/json":         map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/LegacyError"}},
+				},
+			}
+		}
+
+		operation := map[string]any{
+			"summary":     op.summary,
+			"tags":        []string{op.tag},
+			"operationId": operationID(op.method, path),
+			"responses":   responses,
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if op.auth != "" {
+			operation["security"] = []any{map[string]any{"bearerAuth": []string{}}}
+			if op.auth != "*" {
+				operation["description"] = "Requires the " + op.auth + " permission."
+			}
+		}
+		if op.body != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  content(op.bodyTypes, b.schemaFor(reflect.TypeOf(op.body))),
+			}
+		}
+
+		item, ok := paths[path].(map[string]any)
+		if !ok {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(op.method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "Products and Reviews API",
+			"version": appVersion,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "description": "a token from POST /v1/tokens/authentication"},
+			},
+		},
+	}
+}
+
+// operationID derives a stable identifier, e.g. GET /v1/products/{id} -> get_v1_products_by_id
+func operationID(method string, path string) string {
+	replacer := strings.NewReplacer("/", "_", "{", "by_", "}", "", ".", "_")
+	return strings.ToLower(method) + replacer.Replace(path)
+}
+
+// openAPIHandler serves the generated document, building it on first use
+func (a *applicationDependencies) openAPIHandler(router *routeTable) http.HandlerFunc {
+	var once sync.Once
+	var spec []byte
+	var specErr error
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			spec, specErr = json.MarshalIndent(a.buildOpenAPISpec(router), "", "\t")
+		})
+		if specErr != nil {
+			a.serverErrorResponse(w, r, specErr)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, err := w.Write(spec)
+		if err != nil {
+			a.logError(r, err)
+		}
+	}
+}
+
+// docsHandler serves a self-contained HTML page that renders the document
+func (a *applicationDependencies) docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := w.Write(docsPage)
+	if err != nil {
+		a.logError(r, err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newTestApplication() *applicationDependencies {
+	return &applicationDependencies{logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func TestRoutesAreDocumented(t *testing.T) {
+	router := newTestApplication().router()
+
+	for _, route := range undocumentedRoutes(router) {
+		t.Errorf("%s %s is registered but has no entry in apiOperations", route.method, route.path)
+	}
+}
+
+func TestDocumentedRoutesAreRegistered(t *testing.T) {
+	router := newTestApplication().router()
+
+	for _, op := range apiOperations() {
+		if handle, _, _ := router.Lookup(op.method, op.path); handle == nil {
+			t.Errorf("%s %s is documented but not registered", op.method, op.path)
+		}
+	}
+}
+
+func TestUndocumentedRoutes(t *testing.T) {
+	router := newTestApplication().router()
+	router.HandlerFunc("GET", "/v1/undocumented", nil)
+
+	missing := undocumentedRoutes(router)
+	if len(missing) != 1 || missing[0] != (apiRoute{method: "GET", path: "/v1/undocumented"}) {
+		t.Errorf("got %v, want only GET /v1/undocumented", missing)
+	}
+}
+
+func TestBuildOpenAPISpec(t *testing.T) {
+	app := newTestApplication()
+	spec := app.buildOpenAPISpec(app.router())
+
+	paths, ok := spec["paths"].(map[string]any)
+	if !ok || len(paths) == 0 {
+		t.Fatal("the document has no paths")
+	}
+	if _, ok := paths["/v1/products/{id}"]; !ok {
+		t.Error("the document is missing /v1/products/{id}")
+	}
+}
+
+// successStatuses are the net/http constants a handler answers success with
+var successStatuses = map[string]int{
+	"StatusOK":        http.StatusOK,
+	"StatusCreated":   http.StatusCreated,
+	"StatusAccepted":  http.StatusAccepted,
+	"StatusNoContent": http.StatusNoContent,
+}
+
+// TestDocumentedStatuses checks each operation documents every success
+// status its handler can send, and no others. The statuses are read from
+// the source: routes.go names each route's handler, and the handler's body
+// names the http.Status constants it writes.
+func TestDocumentedStatuses(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := make(map[string]*ast.FuncDecl)
+	handlers := make(map[apiRoute]string)
+	for _, file := range pkgs["main"].Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if n.Recv != nil {
+					methods[n.Name.Name] = n
+				}
+			case *ast.CallExpr:
+				if route, handler, ok := routeRegistration(n); ok {
+					handlers[route] = handler
+				}
+			}
+			return true
+		})
+	}
+
+	for _, op := range apiOperations() {
+		name := handlers[apiRoute{method: op.method, path: op.path}]
+		handler, ok := methods[name]
+		if !ok {
+			continue
+		}
+
+		var sent []int
+		ast.Inspect(handler.Body, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if pkg, ok := sel.X.(*ast.Ident); ok && pkg.Name == "http" {
+					if status, ok := successStatuses[sel.Sel.Name]; ok && !slices.Contains(sent, status) {
+						sent = append(sent, status)
+					}
+				}
+			}
+			return true
+		})
+		// handlers that leave the status to net/http send 200
+		if len(sent) == 0 {
+			sent = []int{http.StatusOK}
+		}
+
+		documented := append([]int{op.status}, op.statuses...)
+		slices.Sort(sent)
+		slices.Sort(documented)
+		if !slices.Equal(sent, documented) {
+			t.Errorf("%s %s documents %v but %s sends %v", op.method, op.path, documented, name, sent)
+		}
+	}
+
+	// the create endpoints must at least have been checked
+	for _, route := range []apiRoute{
+		{method: http.MethodPost, path: "/v1/products"},
+		{method: http.MethodPost, path: "/v1/products/:id/reviews"},
+		{method: http.MethodPut, path: "/v1/products/:id/reviews/mine"},
+	} {
+		if _, ok := methods[handlers[route]]; !ok {
+			t.Errorf("found no handler for %s %s in routes.go", route.method, route.path)
+		}
+	}
+}
+
+// routeRegistration reads a router.HandlerFunc(http.MethodX, "/path", h)
+// call, returning the route and the name of the innermost handler method
+// in h, e.g. createProductHandler in
+// a.requirePermission("products:edit", a.idempotent(a.createProductHandler))
+func routeRegistration(call *ast.CallExpr) (apiRoute, string, bool) {
+	fn, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || fn.Sel.Name != "HandlerFunc" || len(call.Args) != 3 {
+		return apiRoute{}, "", false
+	}
+	method, ok := call.Args[0].(*ast.SelectorExpr)
+	if !ok || !strings.HasPrefix(method.Sel.Name, "Method") {
+		return apiRoute{}, "", false
+	}
+	lit, ok := call.Args[1].(*ast.BasicLit)
+	if !ok {
+		return apiRoute{}, "", false
+	}
+	path, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return apiRoute{}, "", false
+	}
+
+	var handler string
+	ast.Inspect(call.Args[2], func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && strings.HasSuffix(sel.Sel.Name, "Handler") {
+			handler = sel.Sel.Name
+		}
+		return true
+	})
+	route := apiRoute{method: strings.ToUpper(strings.TrimPrefix(method.Sel.Name, "Method")), path: path}
+	return route, handler, handler != ""
+}
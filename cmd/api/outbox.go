@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+// bindTx returns a copy of the application whose models all run inside tx
+func (a *applicationDependencies) bindTx(tx *sql.Tx) *applicationDependencies {
+	txApp := *a
+	txApp.tx = tx
+	txApp.userModel = data.UserModel{DB: tx}
+	txApp.tokenModel = data.TokenModel{DB: tx}
+	txApp.permissionModel = data.PermissionModel{DB: tx}
+	txApp.productModel = data.ProductModel{DB: tx}
+	txApp.reviewModel = data.ReviewModel{DB: tx}
+	txApp.reportModel = data.ReportModel{DB: tx}
+	txApp.reviewResponseModel = data.ReviewResponseModel{DB: tx}
+	txApp.reviewMediaModel = data.ReviewMediaModel{DB: tx}
+	txApp.productImageModel = data.ProductImageModel{DB: tx}
+	txApp.variantModel = data.VariantModel{DB: tx}
+	txApp.revisionModel = data.RevisionModel{DB: tx}
+	txApp.reviewEventModel = data.ReviewEventModel{DB: tx}
+	txApp.outboxModel = data.OutboxModel{DB: tx}
+	txApp.webhookModel = data.WebhookModel{DB: tx}
+	return &txApp
+}
+
+// atomically runs fn with models bound to a transaction, committing if fn
+// succeeds. Inside a transactional batch it joins the batch's transaction
+// instead, and the batch commits.
+func (a *applicationDependencies) atomically(ctx context.Context, fn func(tx *applicationDependencies) error) error {
+	if a.tx != nil {
+		return fn(a)
+	}
+
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(a.bindTx(tx))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	a.committed()
+	return nil
+}
+
+// committed wakes the event streams and the webhook dispatcher once new
+// events are visible to them
+func (a *applicationDependencies) committed() {
+	a.reviewEvents.notify()
+	a.webhooks.wake()
+}
+
+// publish writes an event to the outbox for webhook delivery. Call it on
+// a transaction-bound application so the event commits with the change.
+func (a *applicationDependencies) publish(eventType string, payload any) error {
+	return a.outboxModel.Insert(eventType, payload)
+}
+
+// cleanupOutbox periodically deletes dispatched events older than the retention period
+func (a *applicationDependencies) cleanupOutbox() {
+	for {
+		time.Sleep(time.Hour)
+		err := a.outboxModel.DeleteDispatchedBefore(time.Now().Add(-a.config.events.retention))
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	}
+}
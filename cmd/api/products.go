@@ -3,20 +3,39 @@ package main
 import (
 	"fmt"
 	"net/http"
-
-	//"strconv"
+	"slices"
+	"strconv"
 
 	"github.com/RayMC17/AWT_Test1/internal/data"
 	"github.com/RayMC17/AWT_Test1/internal/validator"
 )
 
+// productInput is the request body for creating a product
+type productInput struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Category     string `json:"category"`
+	ImageURL     string `json:"image_url"`
+	Status       string `json:"status"`
+	Price        int64  `json:"price"`
+	Currency     string `json:"currency"`
+	Availability string `json:"availability"`
+}
+
+// productUpdateInput is the plain JSON body for updating a product; nil fields are left unchanged
+type productUpdateInput struct {
+	Name         *string `json:"name"`
+	Description  *string `json:"description"`
+	Category     *string `json:"category"`
+	ImageURL     *string `json:"image_url"`
+	Status       *string `json:"status"`
+	Price        *int64  `json:"price"`
+	Currency     *string `json:"currency"`
+	Availability *string `json:"availability"`
+}
+
 func (a *applicationDependencies) createProductHandler(w http.ResponseWriter, r *http.Request) {
-	var input struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
-		Category    string `json:"category"`
-		ImageURL    string `json:"image_url"`
-	}
+	var input productInput
 
 	err := a.readJSON(w, r, &input)
 	if err != nil {
@@ -25,22 +44,19 @@ func (a *applicationDependencies) createProductHandler(w http.ResponseWriter, r
 	}
 
 	product := &data.Product{
-		Name:        input.Name,
-		Description: input.Description,
-		Category:    input.Category,
-		ImageURL:    input.ImageURL,
+		Name:         input.Name,
+		Description:  input.Description,
+		Category:     input.Category,
+		ImageURL:     input.ImageURL,
+		Status:       input.Status,
+		Price:        input.Price,
+		Currency:     input.Currency,
+		Availability: input.Availability,
 	}
 
-	v := validator.New()
-	data.ValidateProduct(v, product)
-	if !v.IsEmpty() {
-		a.failedValidationResponse(w, r, v.Errors)
-		return
-	}
-
-	err = a.productModel.Insert(product)
+	err = a.createProduct(r.Context(), product)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		a.catalogErrorResponse(w, r, err)
 		return
 	}
 
@@ -53,8 +69,18 @@ func (a *applicationDependencies) createProductHandler(w http.ResponseWriter, r
 }
 
 func (a *applicationDependencies) listProductsHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(r)
+	if !ok {
+		a.notAcceptableResponse(w, r)
+		return
+	}
+
 	name := r.URL.Query().Get("name")
 	category := r.URL.Query().Get("category")
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = data.ProductActive
+	}
 
 	// Initialize filters from query parameters
 	filters := data.Filters{
@@ -65,14 +91,19 @@ func (a *applicationDependencies) listProductsHandler(w http.ResponseWriter, r *
 
 	// Check if the sort parameter is valid
 	v := validator.New()
-	filters.ValidateSort(v)
+	readPriceFilters(r, &filters, v)
+	filters.ValidateSort(v, data.ProductSortSafelist)
+	v.Check(slices.Contains(data.ProductStatuses, status), "status", "must be draft, active or archived")
 
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
 		return
 	}
+	if !a.allowProductStatus(w, r, status) {
+		return
+	}
 	// Retrieve products based on filters
-	products, err := a.productModel.GetAll(name, category, filters)
+	products, err := a.productModel.GetAll(name, category, status, filters)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -85,20 +116,26 @@ func (a *applicationDependencies) listProductsHandler(w http.ResponseWriter, r *
 	}
 
 	// Return the list of products
-	err = a.writeJSON(w, http.StatusOK, envelope{"products": products}, nil)
+	err = a.render(w, http.StatusOK, format, envelope{"products": products}, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
 func (a *applicationDependencies) showProductHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(r)
+	if !ok {
+		a.notAcceptableResponse(w, r)
+		return
+	}
+
 	id, err := a.readIDParam(r)
 	if err != nil {
 		a.notFoundResponse(w, r, "")
 		return
 	}
 
-	product, err := a.productModel.Get(id)
+	product, err := a.getVisibleProduct(r, id)
 	if err != nil {
 		if err.Error() == "product not found" {
 			a.notFoundResponse(w, r, "")
@@ -108,12 +145,24 @@ func (a *applicationDependencies) showProductHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	err = a.writeJSON(w, http.StatusOK, envelope{"product": product}, nil)
+	err = a.render(w, http.StatusOK, format, envelope{"product": product}, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
+// productPatch holds the product fields a merge patch or JSON patch may change
+type productPatch struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Category     string `json:"category"`
+	ImageURL     string `json:"image_url"`
+	Status       string `json:"status"`
+	Price        int64  `json:"price"`
+	Currency     string `json:"currency"`
+	Availability string `json:"availability"`
+}
+
 func (a *applicationDependencies) updateProductHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := a.readIDParam(r)
 	if err != nil {
@@ -121,48 +170,83 @@ func (a *applicationDependencies) updateProductHandler(w http.ResponseWriter, r
 		return
 	}
 
-	product, err := a.productModel.Get(id)
+	product, err := a.getVisibleProduct(r, id)
 	if err != nil {
 		a.notFoundResponse(w, r, "")
 		return
 	}
+	previous := *product
 
-	var input struct {
-		Name        *string `json:"name"`
-		Description *string `json:"description"`
-		Category    *string `json:"category"`
-		ImageURL    *string `json:"image_url"`
-	}
+	switch mediaType := requestMediaType(r); mediaType {
+	case mediaMergePatch, mediaJSONPatch:
+		fields := productPatch{
+			Name:         product.Name,
+			Description:  product.Description,
+			Category:     product.Category,
+			ImageURL:     product.ImageURL,
+			Status:       product.Status,
+			Price:        product.Price,
+			Currency:     product.Currency,
+			Availability: product.Availability,
+		}
 
-	err = a.readJSON(w, r, &input)
-	if err != nil {
-		a.badRequestResponse(w, r, err)
-		return
-	}
+		err = a.readPatch(w, r, mediaType, &fields)
+		if err != nil {
+			a.patchErrorResponse(w, r, err)
+			return
+		}
 
-	if input.Name != nil {
-		product.Name = *input.Name
-	}
-	if input.Description != nil {
-		product.Description = *input.Description
-	}
-	if input.Category != nil {
-		product.Category = *input.Category
-	}
-	if input.ImageURL != nil {
-		product.ImageURL = *input.ImageURL
-	}
+		product.Name = fields.Name
+		product.Description = fields.Description
+		product.Category = fields.Category
+		product.ImageURL = fields.ImageURL
+		product.Status = fields.Status
+		product.Price = fields.Price
+		product.Currency = fields.Currency
+		product.Availability = fields.Availability
 
-	v := validator.New()
-	data.ValidateProduct(v, product)
-	if !v.IsEmpty() {
-		a.failedValidationResponse(w, r, v.Errors)
+	case "", "application/json":
+		var input productUpdateInput
+
+		err = a.readJSON(w, r, &input)
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+
+		if input.Name != nil {
+			product.Name = *input.Name
+		}
+		if input.Description != nil {
+			product.Description = *input.Description
+		}
+		if input.Category != nil {
+			product.Category = *input.Category
+		}
+		if input.ImageURL != nil {
+			product.ImageURL = *input.ImageURL
+		}
+		if input.Status != nil {
+			product.Status = *input.Status
+		}
+		if input.Price != nil {
+			product.Price = *input.Price
+		}
+		if input.Currency != nil {
+			product.Currency = *input.Currency
+		}
+		if input.Availability != nil {
+			product.Availability = *input.Availability
+		}
+
+	default:
+		a.unsupportedMediaTypeResponse(w, r, "application/json, "+mediaMergePatch+", "+mediaJSONPatch)
 		return
 	}
 
-	err = a.productModel.Update(product)
+	err = a.updateProduct(r.Context(), a.contextGetUser(r), &previous, product)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		a.catalogErrorResponse(w, r, err)
 		return
 	}
 
@@ -179,7 +263,17 @@ func (a *applicationDependencies) deleteProductHandler(w http.ResponseWriter, r
 		return
 	}
 
-	err = a.productModel.Delete(id)
+	_, err = a.getVisibleProduct(r, id)
+	if err != nil {
+		if err.Error() == "product not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.deleteProduct(r.Context(), id)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -190,3 +284,46 @@ func (a *applicationDependencies) deleteProductHandler(w http.ResponseWriter, r
 		a.serverErrorResponse(w, r, err)
 	}
 }
+
+// getVisibleProduct loads a product for the request's user, as
+// visibleProduct does
+func (a *applicationDependencies) getVisibleProduct(r *http.Request, id int64) (*data.Product, error) {
+	return a.visibleProduct(a.contextGetUser(r), id)
+}
+
+// readPriceFilters sets the min_price, max_price and in_stock filters from
+// the query string, adding a validation error for any that don't parse
+func readPriceFilters(r *http.Request, filters *data.Filters, v *validator.Validator) {
+	qs := r.URL.Query()
+	if s := qs.Get("min_price"); s != "" {
+		price, err := strconv.ParseInt(s, 10, 64)
+		v.Check(err == nil, "min_price", "must be an integer amount in minor units")
+		filters.MinPrice = &price
+	}
+	if s := qs.Get("max_price"); s != "" {
+		price, err := strconv.ParseInt(s, 10, 64)
+		v.Check(err == nil, "max_price", "must be an integer amount in minor units")
+		filters.MaxPrice = &price
+	}
+	if s := qs.Get("in_stock"); s != "" {
+		inStock, err := strconv.ParseBool(s)
+		v.Check(err == nil, "in_stock", "must be true or false")
+		filters.InStock = &inStock
+	}
+	filters.ValidatePriceRange(v)
+}
+
+// allowProductStatus checks the request's user may see products in status,
+// sending an error response and returning false if they can't
+func (a *applicationDependencies) allowProductStatus(w http.ResponseWriter, r *http.Request, status string) bool {
+	visible, err := a.productStatusVisible(a.contextGetUser(r), status)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return false
+	}
+	if !visible {
+		a.notPermittedResponse(w, r)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output formats the renderer knows how to produce
+const (
+	formatJSON   = "application/json"
+	formatCSV    = "text/csv"
+	formatNDJSON = "application/x-ndjson"
+	formatXML    = "application/xml"
+)
+
+// mediaRange is one entry from an Accept header
+type mediaRange struct {
+	mediaType string
+	q         float64
+	order     int
+}
+
+// negotiate picks the best output format for the request from its
+// Accept header. A missing header or a wildcard falls back to JSON.
+// ok is false when the client only accepts formats we cannot produce.
+func negotiate(r *http.Request) (format string, ok bool) {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return formatJSON, true
+	}
+
+	var ranges []mediaRange
+	for i, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mr := mediaRange{
+			mediaType: strings.ToLower(strings.TrimSpace(fields[0])),
+			q:         1,
+			order:     i,
+		}
+		for _, param := range fields[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err == nil {
+					mr.q = q
+				}
+			}
+		}
+		if mr.q > 0 {
+			ranges = append(ranges, mr)
+		}
+	}
+
+	// highest quality first, ties keep the client's order
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	for _, mr := range ranges {
+		switch mr.mediaType {
+		case "application/json", "application/problem+json", "application/*", "*/*":
+			return formatJSON, true
+		case "text/csv", "text/*":
+			return formatCSV, true
+		case "application/x-ndjson", "application/ndjson":
+			return formatNDJSON, true
+		case "application/xml", "text/xml":
+			return formatXML, true
+		}
+	}
+
+	return "", false
+}
+
+// render writes data in the negotiated format. JSON keeps the envelope;
+// the other formats expect an envelope with a single key whose value is
+// either one record or a slice of records.
+func (a *applicationDependencies) render(w http.ResponseWriter, status int, format string, data envelope, headers http.Header) error {
+	if format == formatJSON || format == "" {
+		return a.writeJSON(w, status, data, headers)
+	}
+
+	if len(data) != 1 {
+		return fmt.Errorf("render: %s output needs a single-key envelope, got %d keys", format, len(data))
+	}
+	var key string
+	var value any
+	for k, v := range data {
+		key, value = k, v
+	}
+
+	for k, v := range headers {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Content-Type", format+"; charset=utf-8")
+	w.WriteHeader(status)
+
+	switch format {
+	case formatCSV:
+		return writeCSV(w, value)
+	case formatNDJSON:
+		return writeNDJSON(w, value)
+	case formatXML:
+		return writeXML(w, key, value)
+	}
+	return fmt.Errorf("render: unsupported format %q", format)
+}
+
+// records turns a single record or a slice of records into a slice
+func records(value any) []reflect.Value {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Slice {
+		rows := make([]reflect.Value, v.Len())
+		for i := range rows {
+			rows[i] = v.Index(i)
+		}
+		return rows
+	}
+	return []reflect.Value{v}
+}
+
+// jsonFields returns the JSON names and values of a struct's exported
+// fields, skipping anything tagged json:"-"
+func jsonFields(v reflect.Value) ([]string, []reflect.Value) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var names []string
+	var values []reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+		values = append(values, v.Field(i))
+	}
+	return names, values
+}
+
+// scalarText formats a field value as plain text. Nested structures are
+// written as compact JSON so no information is lost.
+func scalarText(v reflect.Value) string {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map, reflect.Array:
+		js, err := json.Marshal(v.Interface())
+		if err != nil {
+			return ""
+		}
+		return string(js)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func writeCSV(w io.Writer, value any) error {
+	rows := records(value)
+	cw := csv.NewWriter(w)
+
+	headerWritten := false
+	for _, row := range rows {
+		names, values := jsonFields(row)
+		if !headerWritten {
+			if err := cw.Write(names); err != nil {
+				return err
+			}
+			headerWritten = true
+		}
+		line := make([]string, len(values))
+		for i, fv := range values {
+			line[i] = scalarText(fv)
+		}
+		if err := cw.Write(line); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeNDJSON(w io.Writer, value any) error {
+	enc := json.NewEncoder(w)
+	for _, row := range records(value) {
+		if err := enc.Encode(row.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// singular derives an element name for items in a list, e.g. products -> product
+func singular(name string) string {
+	if strings.HasSuffix(name, "s") && len(name) > 1 {
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "_item"
+}
+
+func writeXML(w io.Writer, key string, value any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+
+	if err := encodeXMLValue(enc, key, reflect.ValueOf(value)); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	_, isTime := v.Interface().(time.Time)
+	switch {
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeXMLValue(enc, singular(name), v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+
+	case v.Kind() == reflect.Struct && !isTime:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		names, values := jsonFields(v)
+		for i, fv := range values {
+			if err := encodeXMLValue(enc, names[i], fv); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+
+	case v.Kind() == reflect.Map:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			if err := encodeXMLValue(enc, fmt.Sprint(k.Interface()), v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}
+
+	return enc.EncodeElement(scalarText(v), start)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// reportInput is the request body for reporting a review
+type reportInput struct {
+	Reason  string `json:"reason"`
+	Details string `json:"details"`
+}
+
+// createReportHandler records the caller's report against a public review.
+// Once a review collects the configured number of open reports it is
+// hidden until a moderator looks at it.
+func (a *applicationDependencies) createReportHandler(w http.ResponseWriter, r *http.Request) {
+	productID, id, err := a.readReviewIDParams(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	review, err := a.reviewModel.Get(id)
+	if err != nil || review.ProductID != productID || review.Status != data.ReviewApproved {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	var input reportInput
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	report := &data.Report{
+		ReviewID:   review.ID,
+		ReporterID: a.contextGetUser(r).ID,
+		Reason:     input.Reason,
+		Details:    input.Details,
+	}
+
+	v := validator.New()
+	data.ValidateReport(v, report)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		err := tx.reportModel.Insert(report)
+		if err != nil {
+			return err
+		}
+		return tx.hideReportedReview(review)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateReport) {
+			a.duplicateReportResponse(w, r)
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusCreated, envelope{"report": report}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// hideReportedReview hides review once its open reports reach the
+// configured threshold. A threshold of zero never hides a review.
+func (a *applicationDependencies) hideReportedReview(review *data.Review) error {
+	threshold := a.config.reports.threshold
+	if threshold <= 0 {
+		return nil
+	}
+
+	count, err := a.reportModel.CountOpen(review.ID)
+	if err != nil || count < threshold {
+		return err
+	}
+
+	review.Status = data.ReviewHidden
+	review.ModerationReason = fmt.Sprintf("automatically hidden after %d reports", count)
+	err = a.reviewModel.Moderate(review, 0)
+	if err != nil {
+		return err
+	}
+	return a.reviewChanged(data.EventReviewModerated, review)
+}
+
+// listReportsHandler is the moderators' reports view: open reports
+// grouped by review with a count per reason, most reported first.
+func (a *applicationDependencies) listReportsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	status := qs.Get("status")
+	filters := data.Filters{
+		Limit:  parseInt(qs.Get("limit"), 10),
+		Offset: parseInt(qs.Get("offset"), 0),
+	}
+
+	v := validator.New()
+	v.Check(status == "" || slices.Contains(data.ReviewStatuses, status), "status", "must be pending, approved, rejected or hidden")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	summaries, err := a.reportModel.Summaries(status, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"reports": summaries}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listReviewReportsHandler lists every report against one review, open
+// and resolved, newest first.
+func (a *applicationDependencies) listReviewReportsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	_, err = a.reviewModel.Get(id)
+	if err != nil {
+		if err.Error() == "review not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	reports, err := a.reportModel.ForReview(id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"reports": reports}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// responseInput is the request body for writing an official response
+type responseInput struct {
+	Content string `json:"content"`
+}
+
+// publicReview loads the review named by the route, or sends a 404 and
+// returns nil if it doesn't exist, belongs to another product or isn't
+// approved
+func (a *applicationDependencies) publicReview(w http.ResponseWriter, r *http.Request) *data.Review {
+	productID, id, err := a.readReviewIDParams(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return nil
+	}
+
+	review, err := a.reviewModel.Get(id)
+	if err != nil {
+		if err.Error() == "review not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	if review.ProductID != productID || review.Status != data.ReviewApproved {
+		a.notFoundResponse(w, r, "")
+		return nil
+	}
+	return review
+}
+
+// createResponseHandler adds the official response to a review. A review
+// can only have one; change it with PATCH instead.
+func (a *applicationDependencies) createResponseHandler(w http.ResponseWriter, r *http.Request) {
+	review := a.publicReview(w, r)
+	if review == nil {
+		return
+	}
+
+	var input responseInput
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	response := &data.ReviewResponse{
+		ReviewID: review.ID,
+		UserID:   a.contextGetUser(r).ID,
+		Content:  input.Content,
+	}
+
+	v := validator.New()
+	data.ValidateReviewResponse(v, response)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.reviewResponseModel.Insert(response)
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateResponse) {
+			a.duplicateResponseResponse(w, r)
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", r.URL.Path)
+	err = a.writeJSON(w, http.StatusCreated, envelope{"response": response}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// showResponseHandler returns the official response to a review
+func (a *applicationDependencies) showResponseHandler(w http.ResponseWriter, r *http.Request) {
+	review := a.publicReview(w, r)
+	if review == nil {
+		return
+	}
+	if review.Response == nil {
+		a.notFoundResponse(w, r, "this review has no response")
+		return
+	}
+
+	err := a.writeJSON(w, http.StatusOK, envelope{"response": review.Response}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// updateResponseHandler rewrites the official response to a review
+func (a *applicationDependencies) updateResponseHandler(w http.ResponseWriter, r *http.Request) {
+	review := a.publicReview(w, r)
+	if review == nil {
+		return
+	}
+	response := review.Response
+	if response == nil {
+		a.notFoundResponse(w, r, "this review has no response")
+		return
+	}
+
+	var input responseInput
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	response.Content = input.Content
+	response.UserID = a.contextGetUser(r).ID
+
+	v := validator.New()
+	data.ValidateReviewResponse(v, response)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.reviewResponseModel.Update(response)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"response": response}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteResponseHandler removes the official response to a review
+func (a *applicationDependencies) deleteResponseHandler(w http.ResponseWriter, r *http.Request) {
+	review := a.publicReview(w, r)
+	if review == nil {
+		return
+	}
+	if review.Response == nil {
+		a.notFoundResponse(w, r, "this review has no response")
+		return
+	}
+
+	err := a.reviewResponseModel.Delete(review.Response.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "response successfully deleted"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
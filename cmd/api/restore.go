@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+// restoreProductHandler brings back a deleted product together with the
+// reviews that were deleted with it
+func (a *applicationDependencies) restoreProductHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	var product *data.Product
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		var err error
+		product, err = tx.productModel.Restore(id)
+		if err != nil {
+			return err
+		}
+		return tx.publish(data.EventProductRestored, product)
+	})
+	if err != nil {
+		switch {
+		case err.Error() == "product not found":
+			a.notFoundResponse(w, r, "no deleted product with this id")
+		case errors.Is(err, data.ErrDuplicateReview):
+			// an author wrote a new review while the product was deleted
+			a.duplicateReviewResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"product": product}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreReviewHandler brings back a deleted review and recalculates its
+// product's rating
+func (a *applicationDependencies) restoreReviewHandler(w http.ResponseWriter, r *http.Request) {
+	productID, id, err := a.readReviewIDParams(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	var review *data.Review
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		var err error
+		review, err = tx.reviewModel.Restore(id)
+		if err != nil {
+			return err
+		}
+		// the review belongs to another product; returning an error rolls the restore back
+		if review.ProductID != productID {
+			return errors.New("review not found")
+		}
+		return tx.reviewChanged(data.EventReviewRestored, review)
+	})
+	if err != nil {
+		switch {
+		case err.Error() == "review not found":
+			a.notFoundResponse(w, r, "no deleted review with this id, or its product is deleted")
+		case errors.Is(err, data.ErrDuplicateReview):
+			a.duplicateReviewResponse(w, r)
+		default:
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeDeleted periodically removes products and reviews that have been
+// deleted for longer than the retention period, along with their images
+func (a *applicationDependencies) purgeDeleted() {
+	if a.config.deleted.retention <= 0 {
+		return
+	}
+	for {
+		time.Sleep(time.Hour)
+		cutoff := time.Now().Add(-a.config.deleted.retention)
+		keys, err := a.reviewMediaModel.PurgeDeleted(cutoff)
+		a.purgeFiles(keys, err)
+		err = a.reviewModel.PurgeDeleted(cutoff)
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+		keys, err = a.productImageModel.PurgeDeleted(cutoff)
+		a.purgeFiles(keys, err)
+		err = a.productModel.PurgeDeleted(cutoff)
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	}
+}
+
+// purgeFiles deletes the stored files whose records were just purged
+func (a *applicationDependencies) purgeFiles(keys []string, err error) {
+	if err != nil {
+		a.logger.Error(err.Error())
+	}
+	for _, key := range keys {
+		err := a.store.Delete(context.Background(), key)
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	}
+}
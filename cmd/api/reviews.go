@@ -7,8 +7,72 @@ import (
 
 	"github.com/RayMC17/AWT_Test1/internal/data"
 	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/julienschmidt/httprouter"
 )
 
+// reviewInput is the request body for creating a review
+type reviewInput struct {
+	Content   string `json:"content"`
+	Author    string `json:"author"`
+	Rating    int    `json:"rating"`
+	VariantID int64  `json:"variant_id"`
+}
+
+// reviewUpdateInput is the plain JSON body for updating a review; nil fields are left unchanged
+type reviewUpdateInput struct {
+	Content   *string `json:"content"`
+	Author    *string `json:"author"`
+	Rating    *int    `json:"rating"`
+	VariantID *int64  `json:"variant_id"`
+}
+
+// ownedReview loads the review named by the route, or sends an error
+// response and returns nil. Only the review's author can change it;
+// moderators can too when moderators is set. Anyone else is told a review
+// that isn't public doesn't exist.
+func (a *applicationDependencies) ownedReview(w http.ResponseWriter, r *http.Request, moderators bool) *data.Review {
+	productID, id, err := a.readReviewIDParams(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return nil
+	}
+
+	review, err := a.reviewModel.Get(id)
+	if err != nil {
+		if err.Error() == "review not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	if review.ProductID != productID {
+		a.notFoundResponse(w, r, "")
+		return nil
+	}
+
+	user := a.contextGetUser(r)
+	if review.UserID != 0 && review.UserID == user.ID {
+		return review
+	}
+	if moderators {
+		permitted, err := a.hasPermission(r, "reviews:moderate")
+		if err != nil {
+			a.serverErrorResponse(w, r, err)
+			return nil
+		}
+		if permitted {
+			return review
+		}
+	}
+	if review.Status != data.ReviewApproved {
+		a.notFoundResponse(w, r, "")
+	} else {
+		a.notPermittedResponse(w, r)
+	}
+	return nil
+}
+
 func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *http.Request) {
 	productID, err := a.readIDParam(r)
 	if err != nil {
@@ -16,12 +80,18 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	var input struct {
-		Content string `json:"content"`
-		Author  string `json:"author"`
-		Rating  int    `json:"rating"`
+	product, err := a.getVisibleProduct(r, productID)
+	if err != nil {
+		if err.Error() == "product not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
+	var input reviewInput
+
 	err = a.readJSON(w, r, &input)
 	if err != nil {
 		a.badRequestResponse(w, r, err)
@@ -33,25 +103,16 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 		Content:   input.Content,
 		Author:    input.Author,
 		Rating:    input.Rating,
+		VariantID: input.VariantID,
 	}
-
-	v := validator.New()
-	data.ValidateReview(v, review)
-	if !v.IsEmpty() {
-		a.failedValidationResponse(w, r, v.Errors)
-		return
+	// a signed-in reviewer's review is theirs, which limits them to one per product
+	if user := a.contextGetUser(r); !user.IsAnonymous() {
+		review.UserID = user.ID
 	}
 
-	err = a.reviewModel.Insert(review)
+	err = a.createReview(r.Context(), product, review)
 	if err != nil {
-		a.serverErrorResponse(w, r, err)
-		return
-	}
-
-	// Update product's average rating after creating a new review
-	err = a.productModel.UpdateAverageRating(productID)
-	if err != nil {
-		a.serverErrorResponse(w, r, err)
+		a.catalogErrorResponse(w, r, err)
 		return
 	}
 
@@ -63,16 +124,19 @@ func (a *applicationDependencies) createReviewHandler(w http.ResponseWriter, r *
 	}
 }
 
-func (a *applicationDependencies) showReviewHandler(w http.ResponseWriter, r *http.Request) {
-	id, err := a.readIDParam(r)
+// putMyReviewHandler creates the caller's review of a product, or
+// replaces it if they have already written one. Replacing keeps the
+// review's ID and helpful votes.
+func (a *applicationDependencies) putMyReviewHandler(w http.ResponseWriter, r *http.Request) {
+	productID, err := a.readIDParam(r)
 	if err != nil {
 		a.notFoundResponse(w, r, "")
 		return
 	}
 
-	review, err := a.reviewModel.Get(id)
+	product, err := a.getVisibleProduct(r, productID)
 	if err != nil {
-		if err.Error() == "review not found" {
+		if err.Error() == "product not found" {
 			a.notFoundResponse(w, r, "")
 		} else {
 			a.serverErrorResponse(w, r, err)
@@ -80,14 +144,69 @@ func (a *applicationDependencies) showReviewHandler(w http.ResponseWriter, r *ht
 		return
 	}
 
-	err = a.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	var input reviewInput
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := a.contextGetUser(r)
+	if input.Author == "" {
+		input.Author = user.Name
+	}
+
+	review, err := a.reviewModel.GetForUser(productID, user.ID)
+	created := false
+	var previous data.Review
+	switch {
+	case err == nil:
+		previous = *review
+	case err.Error() == "review not found":
+		review = &data.Review{ProductID: productID, UserID: user.ID}
+		created = true
+	default:
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	review.Content = input.Content
+	review.Author = input.Author
+	review.Rating = input.Rating
+	review.VariantID = input.VariantID
+
+	// a concurrent request may create the review first, which createReview
+	// reports as a duplicate
+	if created {
+		err = a.createReview(r.Context(), product, review)
+	} else {
+		err = a.updateReview(r.Context(), user, &previous, review)
+	}
+	if err != nil {
+		a.catalogErrorResponse(w, r, err)
+		return
+	}
+
+	status := http.StatusOK
+	headers := make(http.Header)
+	if created {
+		status = http.StatusCreated
+		headers.Set("Location", fmt.Sprintf("/v1/products/%d/reviews/%d", review.ProductID, review.ID))
+	}
+	err = a.writeJSON(w, status, envelope{"review": review}, headers)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
-func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *http.Request) {
-	id, err := a.readIDParam(r)
+func (a *applicationDependencies) showReviewHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(r)
+	if !ok {
+		a.notAcceptableResponse(w, r)
+		return
+	}
+
+	productID, id, err := a.readReviewIDParams(r)
 	if err != nil {
 		a.notFoundResponse(w, r, "")
 		return
@@ -95,93 +214,167 @@ func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *
 
 	review, err := a.reviewModel.Get(id)
 	if err != nil {
-		a.notFoundResponse(w, r, "")
+		if err.Error() == "review not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
-	var input struct {
-		Content *string `json:"content"`
-		Author  *string `json:"author"`
-		Rating  *int    `json:"rating"`
+	// the review must belong to the product in the URL and be public
+	if review.ProductID != productID || review.Status != data.ReviewApproved {
+		a.notFoundResponse(w, r, "")
+		return
 	}
 
-	err = a.readJSON(w, r, &input)
+	err = a.render(w, http.StatusOK, format, envelope{"review": review}, nil)
 	if err != nil {
-		a.badRequestResponse(w, r, err)
-		return
+		a.serverErrorResponse(w, r, err)
 	}
+}
 
-	if input.Content != nil {
-		review.Content = *input.Content
-	}
-	if input.Author != nil {
-		review.Author = *input.Author
+// reviewPatch holds the review fields a merge patch or JSON patch may change
+type reviewPatch struct {
+	Content   string `json:"content"`
+	Author    string `json:"author"`
+	Rating    int    `json:"rating"`
+	VariantID int64  `json:"variant_id"`
+}
+
+// updateReviewHandler changes a review on behalf of its author or a moderator
+func (a *applicationDependencies) updateReviewHandler(w http.ResponseWriter, r *http.Request) {
+	review := a.ownedReview(w, r, true)
+	if review == nil {
+		return
 	}
-	if input.Rating != nil {
-		review.Rating = *input.Rating
+	previous := *review
+
+	var err error
+
+	switch mediaType := requestMediaType(r); mediaType {
+	case mediaMergePatch, mediaJSONPatch:
+		fields := reviewPatch{
+			Content:   review.Content,
+			Author:    review.Author,
+			Rating:    review.Rating,
+			VariantID: review.VariantID,
+		}
+
+		err = a.readPatch(w, r, mediaType, &fields)
+		if err != nil {
+			a.patchErrorResponse(w, r, err)
+			return
+		}
+
+		review.Content = fields.Content
+		review.Author = fields.Author
+		review.Rating = fields.Rating
+		review.VariantID = fields.VariantID
+
+	case "", "application/json":
+		var input reviewUpdateInput
+
+		err = a.readJSON(w, r, &input)
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+
+		if input.Content != nil {
+			review.Content = *input.Content
+		}
+		if input.Author != nil {
+			review.Author = *input.Author
+		}
+		if input.Rating != nil {
+			review.Rating = *input.Rating
+		}
+		if input.VariantID != nil {
+			review.VariantID = *input.VariantID
+		}
+
+	default:
+		a.unsupportedMediaTypeResponse(w, r, "application/json, "+mediaMergePatch+", "+mediaJSONPatch)
+		return
 	}
 
-	v := validator.New()
-	data.ValidateReview(v, review)
-	if !v.IsEmpty() {
-		a.failedValidationResponse(w, r, v.Errors)
+	err = a.updateReview(r.Context(), a.contextGetUser(r), &previous, review)
+	if err != nil {
+		a.catalogErrorResponse(w, r, err)
 		return
 	}
 
-	err = a.reviewModel.Update(review)
+	err = a.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteReviewHandler deletes a review on behalf of its author or a moderator
+func (a *applicationDependencies) deleteReviewHandler(w http.ResponseWriter, r *http.Request) {
+	// Fetch the review before deleting to get ProductID for average rating update
+	review := a.ownedReview(w, r, true)
+	if review == nil {
 		return
 	}
 
-	// Update product's average rating after updating a review
-	err = a.productModel.UpdateAverageRating(review.ProductID)
+	err := a.deleteReview(r.Context(), review)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = a.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "review successfully deleted"}, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
-func (a *applicationDependencies) deleteReviewHandler(w http.ResponseWriter, r *http.Request) {
-	id, err := a.readIDParam(r)
+func (a *applicationDependencies) markReviewHelpfulHandler(w http.ResponseWriter, r *http.Request) {
+	productID, id, err := a.readReviewIDParams(r)
 	if err != nil {
 		a.notFoundResponse(w, r, "")
 		return
 	}
 
-	// Fetch the review before deleting to get ProductID for average rating update
 	review, err := a.reviewModel.Get(id)
-	if err != nil {
+	if err != nil || review.ProductID != productID || review.Status != data.ReviewApproved {
 		a.notFoundResponse(w, r, "")
 		return
 	}
 
-	err = a.reviewModel.Delete(id)
-	if err != nil {
-		a.serverErrorResponse(w, r, err)
-		return
-	}
-
-	// Update product's average rating after deleting a review
-	err = a.productModel.UpdateAverageRating(review.ProductID)
+	err = a.markHelpful(r.Context(), a.contextGetUser(r), review)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = a.writeJSON(w, http.StatusOK, envelope{"message": "review successfully deleted"}, nil)
+	err = a.writeJSON(w, http.StatusOK, envelope{"review": review}, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
 }
 
 func (a *applicationDependencies) listReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	format, ok := negotiate(r)
+	if !ok {
+		a.notAcceptableResponse(w, r)
+		return
+	}
+
+	// GET /v1/products/:id/reviews names the product in its path and
+	// GET /v1/reviews in the query string
 	productID, _ := strconv.ParseInt(r.URL.Query().Get("product_id"), 10, 64)
+	if httprouter.ParamsFromContext(r.Context()).ByName("id") != "" {
+		var err error
+		productID, err = a.readIDParam(r)
+		if err != nil {
+			a.notFoundResponse(w, r, "")
+			return
+		}
+	}
+	variantID, _ := strconv.ParseInt(r.URL.Query().Get("variant_id"), 10, 64)
 
 	// Initialize filters from query parameters
 	filters := data.Filters{
@@ -192,7 +385,7 @@ func (a *applicationDependencies) listReviewsHandler(w http.ResponseWriter, r *h
 
 	// Validate sort parameter
 	v := validator.New()
-	filters.ValidateSort(v)
+	filters.ValidateSort(v, data.ReviewSortSafelist)
 
 	if !v.IsEmpty() {
 		a.failedValidationResponse(w, r, v.Errors)
@@ -200,7 +393,7 @@ func (a *applicationDependencies) listReviewsHandler(w http.ResponseWriter, r *h
 	}
 
 	// Pass individual parameters instead of `filters`
-	reviews, err := a.reviewModel.GetAll(productID, filters.Sort, filters.Limit, filters.Offset)
+	reviews, err := a.reviewModel.GetAll(productID, variantID, filters.Sort, filters.Limit, filters.Offset)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 		return
@@ -212,7 +405,7 @@ func (a *applicationDependencies) listReviewsHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	err = a.writeJSON(w, http.StatusOK, envelope{"reviews": reviews}, nil)
+	err = a.render(w, http.StatusOK, format, envelope{"reviews": reviews}, nil)
 	if err != nil {
 		a.serverErrorResponse(w, r, err)
 	}
@@ -2,33 +2,104 @@ package main
 
 import (
     "net/http"
-    "github.com/julienschmidt/httprouter"
 )
 
-func (a *applicationDependencies) routes() http.Handler {
-    router := httprouter.New()
+// router registers every endpoint without the middleware chain. The
+// batch endpoint dispatches its sub-requests through it directly.
+func (a *applicationDependencies) router() *routeTable {
+    router := newRouteTable()
+    router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        a.notFoundResponse(w, r, "")
+    })
+    router.MethodNotAllowed = http.HandlerFunc(a.methodNotAllowedResponse)
 
-    // // User routes
-    // router.HandlerFunc(http.MethodPost, "/v1/users", a.createUserHandler)
-    // router.HandlerFunc(http.MethodGet, "/v1/users/:id", a.showUserHandler)
-    // router.HandlerFunc(http.MethodPatch, "/v1/users/:id", a.updateUserHandler)
-    // router.HandlerFunc(http.MethodDelete, "/v1/users/:id", a.deleteUserHandler)
+    // User and token routes
+    router.HandlerFunc(http.MethodPost, "/v1/users", a.registerUserHandler)
+    router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", a.createAuthenticationTokenHandler)
 
     // Product routes
-    router.HandlerFunc(http.MethodPost, "/v1/products", a.createProductHandler)
+    router.HandlerFunc(http.MethodPost, "/v1/products", a.requirePermission("products:edit", a.idempotent(a.createProductHandler)))
     router.HandlerFunc(http.MethodGet, "/v1/products/:id", a.showProductHandler)
-    router.HandlerFunc(http.MethodPatch, "/v1/products/:id", a.updateProductHandler)
-    router.HandlerFunc(http.MethodDelete, "/v1/products/:id", a.deleteProductHandler)
+    router.HandlerFunc(http.MethodPatch, "/v1/products/:id", a.requirePermission("products:edit", a.updateProductHandler))
+    router.HandlerFunc(http.MethodDelete, "/v1/products/:id", a.requirePermission("products:edit", a.deleteProductHandler))
     router.HandlerFunc(http.MethodGet, "/v1/products", a.listProductsHandler)
+    router.HandlerFunc(http.MethodGet, "/v1/products/:id/history", a.productHistoryHandler)
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/restore", a.requirePermission("catalog:restore", a.restoreProductHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/products/:id/images", a.listProductImagesHandler)
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/images", a.requirePermission("products:edit", a.uploadProductImagesHandler))
+    router.HandlerFunc(http.MethodPut, "/v1/products/:id/images/order", a.requirePermission("products:edit", a.reorderProductImagesHandler))
+    router.HandlerFunc(http.MethodDelete, "/v1/products/:id/images/:image_id", a.requirePermission("products:edit", a.deleteProductImageHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/products/:id/variants", a.listVariantsHandler)
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/variants", a.requirePermission("products:edit", a.createVariantHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/products/:id/variants/:variant_id", a.showVariantHandler)
+    router.HandlerFunc(http.MethodPatch, "/v1/products/:id/variants/:variant_id", a.requirePermission("products:edit", a.updateVariantHandler))
+    router.HandlerFunc(http.MethodDelete, "/v1/products/:id/variants/:variant_id", a.requirePermission("products:edit", a.deleteVariantHandler))
 
     // Review routes
-    router.HandlerFunc(http.MethodPost, "/v1/products/:id/reviews", a.createReviewHandler)
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/reviews", a.idempotent(a.createReviewHandler))
+    router.HandlerFunc(http.MethodPut, "/v1/products/:id/reviews/mine", a.requireAuthenticatedUser(a.putMyReviewHandler))
     router.HandlerFunc(http.MethodGet, "/v1/products/:id/reviews/:review_id", a.showReviewHandler)
-    router.HandlerFunc(http.MethodPatch, "/v1/products/:id/reviews/:review_id", a.updateReviewHandler)
-    router.HandlerFunc(http.MethodDelete, "/v1/products/:id/reviews/:review_id", a.deleteReviewHandler)
+    router.HandlerFunc(http.MethodPatch, "/v1/products/:id/reviews/:review_id", a.requireAuthenticatedUser(a.updateReviewHandler))
+    router.HandlerFunc(http.MethodDelete, "/v1/products/:id/reviews/:review_id", a.requireAuthenticatedUser(a.deleteReviewHandler))
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/reviews/:review_id/helpful", a.requireAuthenticatedUser(a.idempotent(a.markReviewHelpfulHandler)))
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/reviews/:review_id/reports", a.requireAuthenticatedUser(a.createReportHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/products/:id/reviews/:review_id/history", a.requirePermission("reviews:moderate", a.reviewHistoryHandler))
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/reviews/:review_id/restore", a.requirePermission("catalog:restore", a.restoreReviewHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/products/:id/reviews/:review_id/responses", a.showResponseHandler)
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/reviews/:review_id/responses", a.requirePermission("products:respond", a.createResponseHandler))
+    router.HandlerFunc(http.MethodPatch, "/v1/products/:id/reviews/:review_id/responses", a.requirePermission("products:respond", a.updateResponseHandler))
+    router.HandlerFunc(http.MethodDelete, "/v1/products/:id/reviews/:review_id/responses", a.requirePermission("products:respond", a.deleteResponseHandler))
+    router.HandlerFunc(http.MethodPost, "/v1/products/:id/reviews/:review_id/media", a.requireAuthenticatedUser(a.uploadReviewMediaHandler))
+    router.HandlerFunc(http.MethodDelete, "/v1/products/:id/reviews/:review_id/media/:media_id", a.requireAuthenticatedUser(a.deleteReviewMediaHandler))
     router.HandlerFunc(http.MethodGet, "/v1/reviews", a.listReviewsHandler)
     router.HandlerFunc(http.MethodGet, "/v1/products/:id/reviews", a.listReviewsHandler)
 
+    // Moderation routes
+    router.HandlerFunc(http.MethodGet, "/v1/moderation/reviews", a.requirePermission("reviews:moderate", a.listModerationQueueHandler))
+    router.HandlerFunc(http.MethodPost, "/v1/moderation/reviews/:id/transition", a.requirePermission("reviews:moderate", a.moderateReviewHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/moderation/reviews/:id/reports", a.requirePermission("reviews:moderate", a.listReviewReportsHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/moderation/reports", a.requirePermission("reviews:moderate", a.listReportsHandler))
+
+    // Uploaded file routes
+    router.HandlerFunc(http.MethodGet, "/v1/media/*key", a.serveMediaHandler)
+
+    // Event stream routes
+    router.HandlerFunc(http.MethodGet, "/v1/stream/reviews", a.streamReviewsHandler)
+
+    // Webhook routes
+    router.HandlerFunc(http.MethodPost, "/v1/webhooks", a.requirePermission("webhooks:manage", a.createWebhookHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/webhooks", a.requirePermission("webhooks:manage", a.listWebhooksHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id", a.requirePermission("webhooks:manage", a.showWebhookHandler))
+    router.HandlerFunc(http.MethodPatch, "/v1/webhooks/:id", a.requirePermission("webhooks:manage", a.updateWebhookHandler))
+    router.HandlerFunc(http.MethodDelete, "/v1/webhooks/:id", a.requirePermission("webhooks:manage", a.deleteWebhookHandler))
+    router.HandlerFunc(http.MethodGet, "/v1/webhooks/:id/deliveries", a.requirePermission("webhooks:manage", a.listWebhookDeliveriesHandler))
+    router.HandlerFunc(http.MethodPost, "/v1/webhooks/:id/deliveries/:delivery_id/retry", a.requirePermission("webhooks:manage", a.retryWebhookDeliveryHandler))
+
+    // Export routes
+    router.HandlerFunc(http.MethodGet, "/v1/exports/products", a.exportProductsHandler)
+    router.HandlerFunc(http.MethodGet, "/v1/exports/reviews", a.exportReviewsHandler)
+
+    // Import routes
+    router.HandlerFunc(http.MethodPost, "/v1/imports/products", a.requirePermission("products:edit", a.importProductsHandler))
+
+    // Batch route
+    router.HandlerFunc(http.MethodPost, "/v1/batch", a.batchHandler(router))
+
+    // GraphQL routes
+    graphQL := a.graphQLHandler()
+    router.HandlerFunc(http.MethodGet, "/v1/graphql", graphQL)
+    router.HandlerFunc(http.MethodPost, "/v1/graphql", graphQL)
+
+    // API documentation routes
+    router.HandlerFunc(http.MethodGet, "/v1/openapi.json", a.openAPIHandler(router))
+    router.HandlerFunc(http.MethodGet, "/v1/docs", a.docsHandler)
+
+    return router
+}
+
+func (a *applicationDependencies) routes() http.Handler {
+    router := a.router()
+
 //     return a.recoverPanic(router)
-return a.recoverPanic(a.rateLimit(router))
+return a.recoverPanic(a.rateLimit(a.authenticate(router)))
 }
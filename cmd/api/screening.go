@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+// screenReview runs the screening pipeline over a review about to be
+// saved and sets its status, score and flags. Validation errors from
+// rejecting rules are returned for the caller to send back. previousStatus
+// is empty for a new review.
+//
+// A review that passes screening is approved straight away. One that a
+// rule holds, or that was still awaiting a moderator or had been rejected
+// or hidden before it was edited, is left pending for a moderator.
+func (a *applicationDependencies) screenReview(review *data.Review, previousStatus string) (map[string]string, error) {
+	result, err := a.screener.Screen(review)
+	if err != nil {
+		return nil, err
+	}
+	if result.Rejected() {
+		return result.Errors, nil
+	}
+
+	review.ScreeningScore = result.Score
+	review.ScreeningFlags = result.Flags
+
+	switch {
+	case result.Hold, previousStatus == data.ReviewPending, previousStatus == data.ReviewRejected, previousStatus == data.ReviewHidden:
+		review.Status = data.ReviewPending
+	default:
+		review.Status = data.ReviewApproved
+	}
+	return nil, nil
+}
@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 func (a *applicationDependencies) serve() error {
@@ -21,6 +24,31 @@ func (a *applicationDependencies) serve() error {
 		WriteTimeout: 10 * time.Second,
 		ErrorLog:     slog.NewLogLogger(a.logger.Handler(), slog.LevelError),
 	}
+	// a gRPC port of zero disables the gRPC server
+	var grpcServer *grpc.Server
+	var grpcListener net.Listener
+	if a.config.grpc.port != 0 {
+		var err error
+		grpcListener, err = net.Listen("tcp", fmt.Sprintf(":%d", a.config.grpc.port))
+		if err != nil {
+			return err
+		}
+		grpcServer = a.newGRPCServer()
+	}
+
+	// open event streams never go idle, so end them when shutdown starts
+	apiServer.RegisterOnShutdown(a.reviewEvents.close)
+
+	// purge expired Idempotency-Key records and old review events in the background
+	go a.cleanupIdempotencyKeys()
+	go a.cleanupReviewEvents()
+	go a.cleanupOutbox()
+	go a.cleanupTokens()
+	go a.purgeDeleted()
+
+	// deliver webhooks until shutdown drains the dispatcher
+	go a.webhooks.run()
+
 	// create a channel to keep track of any errors during the shutdown process
 	shutdownError := make(chan error)
 	// create a goroutine that runs in the background listening
@@ -35,10 +63,52 @@ func (a *applicationDependencies) serve() error {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 
+		// stop the gRPC server alongside the HTTP server
+		grpcStopped := make(chan struct{})
+		go func() {
+			if grpcServer != nil {
+				grpcServer.GracefulStop()
+			}
+			close(grpcStopped)
+		}()
+
 		// initiate the shutdown. If all okay returns nil
-		shutdownError <- apiServer.Shutdown(ctx)
+		err := apiServer.Shutdown(ctx)
+
+		// force the gRPC server closed if its streams outlive the deadline
+		select {
+		case <-grpcStopped:
+		case <-ctx.Done():
+			if grpcServer != nil {
+				grpcServer.Stop()
+			}
+			<-grpcStopped
+		}
+		if grpcServer != nil {
+			a.logger.Info("stopped gRPC server", "address", grpcListener.Addr().String())
+		}
+
+		// no more requests can write events, so deliver what is left
+		a.logger.Info("draining webhook dispatcher")
+		drainErr := a.webhooks.stop(ctx)
+		if drainErr != nil {
+			a.logger.Warn("webhook dispatcher did not drain in time; undelivered events will be retried on restart")
+		}
+
+		shutdownError <- err
 	}()
 
+	// the gRPC services share the models and run on their own port
+	if grpcServer != nil {
+		go func() {
+			a.logger.Info("starting gRPC server", "address", grpcListener.Addr().String())
+			err := grpcServer.Serve(grpcListener)
+			if err != nil {
+				a.logger.Error(err.Error())
+			}
+		}()
+	}
+
 	a.logger.Info("starting server", "address", apiServer.Addr, "environment", a.config.environment)
 
 	// something went wrong during shutdown if we don't get ErrServerClosed()
@@ -61,4 +131,13 @@ func (a *applicationDependencies) serve() error {
  } 
 	// return apiServer.ListenAndServe()
 
-
+// cleanupIdempotencyKeys periodically deletes expired Idempotency-Key records
+func (a *applicationDependencies) cleanupIdempotencyKeys() {
+	for {
+		time.Sleep(time.Hour)
+		err := a.idempotencyModel.DeleteExpired()
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// credentialsInput is the request body for creating an authentication token
+type credentialsInput struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// createAuthenticationTokenHandler exchanges an email and password for a bearer token
+func (a *applicationDependencies) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input credentialsInput
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateEmail(v, input.Email)
+	data.ValidatePasswordPlaintext(v, input.Password)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := a.userModel.GetByEmail(input.Email)
+	if err != nil {
+		if err.Error() == "user not found" {
+			a.invalidCredentialsResponse(w, r)
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	match, err := user.Password.Matches(input.Password)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+	if !match {
+		a.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	token, err := a.tokenModel.New(user.ID, a.config.auth.tokenTTL, data.ScopeAuthentication)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// cleanupTokens periodically deletes expired tokens
+func (a *applicationDependencies) cleanupTokens() {
+	for {
+		time.Sleep(time.Hour)
+		err := a.tokenModel.DeleteExpired()
+		if err != nil {
+			a.logger.Error(err.Error())
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// variantInput is the request body for creating a variant
+type variantInput struct {
+	SKU        string            `json:"sku"`
+	Attributes map[string]string `json:"attributes"`
+	Price      *int64            `json:"price"`
+}
+
+// variantUpdateInput is the plain JSON body for updating a variant; nil
+// fields are left unchanged. A merge patch can also clear the price.
+type variantUpdateInput struct {
+	SKU        *string            `json:"sku"`
+	Attributes *map[string]string `json:"attributes"`
+	Price      *int64             `json:"price"`
+}
+
+// productForVariants loads the product named by the route, or sends an
+// error response and returns nil
+func (a *applicationDependencies) productForVariants(w http.ResponseWriter, r *http.Request) *data.Product {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return nil
+	}
+
+	product, err := a.getVisibleProduct(r, id)
+	if err != nil {
+		if err.Error() == "product not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	return product
+}
+
+// variantForRoute loads the variant named by the route, checking it
+// belongs to the product, or sends an error response and returns nil
+func (a *applicationDependencies) variantForRoute(w http.ResponseWriter, r *http.Request) *data.Variant {
+	product := a.productForVariants(w, r)
+	if product == nil {
+		return nil
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("variant_id"), 10, 64)
+	if err != nil || id < 1 {
+		a.notFoundResponse(w, r, "")
+		return nil
+	}
+
+	variant, err := a.variantModel.Get(id)
+	if err != nil {
+		if err.Error() == "variant not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return nil
+	}
+	if variant.ProductID != product.ID {
+		a.notFoundResponse(w, r, "")
+		return nil
+	}
+	return variant
+}
+
+// checkReviewVariant adds a validation error when a review names a
+// variant that isn't one of its product's
+func (a *applicationDependencies) checkReviewVariant(v *validator.Validator, review *data.Review) error {
+	if review.VariantID == 0 {
+		return nil
+	}
+	variant, err := a.variantModel.Get(review.VariantID)
+	if err != nil && err.Error() != "variant not found" {
+		return err
+	}
+	v.Check(err == nil && variant.ProductID == review.ProductID, "variant_id", "must be a variant of this product")
+	return nil
+}
+
+func (a *applicationDependencies) createVariantHandler(w http.ResponseWriter, r *http.Request) {
+	product := a.productForVariants(w, r)
+	if product == nil {
+		return
+	}
+
+	var input variantInput
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	variant := &data.Variant{
+		ProductID:  product.ID,
+		SKU:        input.SKU,
+		Attributes: input.Attributes,
+		Price:      input.Price,
+	}
+
+	v := validator.New()
+	data.ValidateVariant(v, variant)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		err := tx.variantModel.Insert(variant)
+		if err != nil {
+			return err
+		}
+		return tx.publish(data.EventVariantCreated, variant)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateSKU) {
+			a.duplicateSKUResponse(w, r)
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/products/%d/variants/%d", product.ID, variant.ID))
+	err = a.writeJSON(w, http.StatusCreated, envelope{"variant": variant}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listVariantsHandler lists a product's variants with the rating of each
+func (a *applicationDependencies) listVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	product := a.productForVariants(w, r)
+	if product == nil {
+		return
+	}
+
+	variants, err := a.variantModel.ForProduct(product.ID)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"variants": variants}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) showVariantHandler(w http.ResponseWriter, r *http.Request) {
+	variant := a.variantForRoute(w, r)
+	if variant == nil {
+		return
+	}
+
+	err := a.writeJSON(w, http.StatusOK, envelope{"variant": variant}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) updateVariantHandler(w http.ResponseWriter, r *http.Request) {
+	variant := a.variantForRoute(w, r)
+	if variant == nil {
+		return
+	}
+
+	switch mediaType := requestMediaType(r); mediaType {
+	case mediaMergePatch, mediaJSONPatch:
+		fields := variantInput{
+			SKU:        variant.SKU,
+			Attributes: variant.Attributes,
+			Price:      variant.Price,
+		}
+
+		err := a.readPatch(w, r, mediaType, &fields)
+		if err != nil {
+			a.patchErrorResponse(w, r, err)
+			return
+		}
+
+		variant.SKU = fields.SKU
+		variant.Attributes = fields.Attributes
+		variant.Price = fields.Price
+
+	case "", "application/json":
+		var input variantUpdateInput
+
+		err := a.readJSON(w, r, &input)
+		if err != nil {
+			a.badRequestResponse(w, r, err)
+			return
+		}
+
+		if input.SKU != nil {
+			variant.SKU = *input.SKU
+		}
+		if input.Attributes != nil {
+			variant.Attributes = *input.Attributes
+		}
+		if input.Price != nil {
+			variant.Price = input.Price
+		}
+
+	default:
+		a.unsupportedMediaTypeResponse(w, r, "application/json, "+mediaMergePatch+", "+mediaJSONPatch)
+		return
+	}
+
+	v := validator.New()
+	data.ValidateVariant(v, variant)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		err := tx.variantModel.Update(variant)
+		if err != nil {
+			return err
+		}
+		return tx.publish(data.EventVariantUpdated, variant)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrDuplicateSKU) {
+			a.duplicateSKUResponse(w, r)
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"variant": variant}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteVariantHandler removes a variant. Its reviews stay on the product.
+func (a *applicationDependencies) deleteVariantHandler(w http.ResponseWriter, r *http.Request) {
+	variant := a.variantForRoute(w, r)
+	if variant == nil {
+		return
+	}
+
+	err := a.atomically(r.Context(), func(tx *applicationDependencies) error {
+		err := tx.variantModel.Delete(variant.ID)
+		if err != nil {
+			return err
+		}
+		return tx.publish(data.EventVariantDeleted, variant)
+	})
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "variant successfully deleted"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/julienschmidt/httprouter"
+)
+
+// webhookInput is the request body for creating a webhook
+type webhookInput struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+}
+
+// webhookUpdateInput is the request body for updating a webhook; nil fields are left unchanged
+type webhookUpdateInput struct {
+	URL         *string   `json:"url"`
+	Description *string   `json:"description"`
+	Events      *[]string `json:"events"`
+	Active      *bool     `json:"active"`
+}
+
+// newWebhookSecret returns a random signing secret
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func (a *applicationDependencies) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input webhookInput
+
+	err := a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook := &data.Webhook{
+		URL:         input.URL,
+		Description: input.Description,
+		Events:      input.Events,
+		Active:      true,
+	}
+
+	v := validator.New()
+	data.ValidateWebhook(v, webhook)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	webhook.Secret, err = newWebhookSecret()
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.webhookModel.Insert(webhook)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// the secret is only ever shown in this response
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/v1/webhooks/%d", webhook.ID))
+	err = a.writeJSON(w, http.StatusCreated, envelope{"webhook": webhook}, headers)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) showWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	webhook, err := a.webhookModel.Get(id)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	filters := data.Filters{
+		Limit:  parseInt(r.URL.Query().Get("limit"), 10),
+		Offset: parseInt(r.URL.Query().Get("offset"), 0),
+	}
+
+	webhooks, err := a.webhookModel.GetAll(filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"webhooks": webhooks}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	webhook, err := a.webhookModel.Get(id)
+	if err != nil {
+		if err.Error() == "webhook not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var input webhookUpdateInput
+
+	err = a.readJSON(w, r, &input)
+	if err != nil {
+		a.badRequestResponse(w, r, err)
+		return
+	}
+
+	if input.URL != nil {
+		webhook.URL = *input.URL
+	}
+	if input.Description != nil {
+		webhook.Description = *input.Description
+	}
+	if input.Events != nil {
+		webhook.Events = *input.Events
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+
+	v := validator.New()
+	data.ValidateWebhook(v, webhook)
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = a.webhookModel.Update(webhook)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+func (a *applicationDependencies) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	_, err = a.webhookModel.Get(id)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	err = a.webhookModel.Delete(id)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"message": "webhook successfully deleted"}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// listWebhookDeliveriesHandler shows a webhook's delivery log; ?status=dead
+// is the dead-letter view of events that used up all their attempts.
+func (a *applicationDependencies) listWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	filters := data.Filters{
+		Limit:  parseInt(r.URL.Query().Get("limit"), 10),
+		Offset: parseInt(r.URL.Query().Get("offset"), 0),
+	}
+
+	v := validator.New()
+	v.Check(status == "" || slices.Contains(data.DeliveryStatuses, status), "status", "must be pending, delivered or dead")
+	if !v.IsEmpty() {
+		a.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	_, err = a.webhookModel.Get(id)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	deliveries, err := a.webhookModel.Deliveries(id, status, filters)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = a.writeJSON(w, http.StatusOK, envelope{"deliveries": deliveries}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
+
+// retryWebhookDeliveryHandler requeues a delivery, typically one from the dead-letter view
+func (a *applicationDependencies) retryWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := a.readIDParam(r)
+	if err != nil {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	deliveryID, err := strconv.ParseInt(params.ByName("delivery_id"), 10, 64)
+	if err != nil || deliveryID < 1 {
+		a.notFoundResponse(w, r, "")
+		return
+	}
+
+	delivery, err := a.webhookModel.Retry(id, deliveryID)
+	if err != nil {
+		if err.Error() == "delivery not found" {
+			a.notFoundResponse(w, r, "")
+		} else {
+			a.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	a.webhooks.wake()
+
+	err = a.writeJSON(w, http.StatusAccepted, envelope{"delivery": delivery}, nil)
+	if err != nil {
+		a.serverErrorResponse(w, r, err)
+	}
+}
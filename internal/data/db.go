@@ -0,0 +1,44 @@
+// internal/data/db.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// DBTX is the subset of database/sql shared by *sql.DB and *sql.Tx, so a
+// model can run either against the pool or inside a caller's transaction.
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// withTx runs fn inside a transaction. When db is already a transaction
+// fn simply joins it and committing is left to whoever opened it.
+func withTx(ctx context.Context, db DBTX, opts *sql.TxOptions, fn func(*sql.Tx) error) error {
+	if tx, ok := db.(*sql.Tx); ok {
+		return fn(tx)
+	}
+
+	pool, ok := db.(*sql.DB)
+	if !ok {
+		return errors.New("data: cannot begin a transaction on this connection")
+	}
+
+	tx, err := pool.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
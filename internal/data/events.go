@@ -0,0 +1,121 @@
+// internal/data/events.go
+package data
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Review event types
+const (
+	EventReviewCreated   = "review.created"
+	EventReviewUpdated   = "review.updated"
+	EventReviewDeleted   = "review.deleted"
+	EventReviewRestored  = "review.restored"
+	EventReviewModerated = "review.moderated"
+	EventRatingChanged   = "rating.changed"
+)
+
+// ReviewEvent is one entry in the log of review activity. IDs increase
+// monotonically, so a client can resume from the last ID it saw.
+type ReviewEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	ProductID int64           `json:"product_id"`
+	ReviewID  int64           `json:"review_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RatingChange is the payload of a rating.changed event.
+type RatingChange struct {
+	ProductID     int64   `json:"product_id"`
+	Previous      float64 `json:"previous"`
+	AverageRating float64 `json:"average_rating"`
+}
+
+type ReviewEventModel struct {
+	DB DBTX
+}
+
+// Insert appends an event to the log, marshalling payload as its data.
+func (m ReviewEventModel) Insert(eventType string, productID int64, reviewID int64, payload any) (*ReviewEvent, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        INSERT INTO review_events (type, product_id, review_id, data)
+        VALUES ($1, $2, NULLIF($3, 0), $4)
+        RETURNING id, created_at`
+
+	event := &ReviewEvent{
+		Type:      eventType,
+		ProductID: productID,
+		ReviewID:  reviewID,
+		Data:      body,
+	}
+	err = m.DB.QueryRow(query, eventType, productID, reviewID, body).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// After returns up to limit events with an ID greater than afterID, oldest
+// first. A productID of zero matches every product.
+func (m ReviewEventModel) After(afterID int64, productID int64, limit int) ([]*ReviewEvent, error) {
+	query := `
+        SELECT id, type, product_id, COALESCE(review_id, 0), data, created_at
+        FROM review_events
+        WHERE id > $1 AND (product_id = $2 OR $2 = 0)
+        ORDER BY id
+        LIMIT $3`
+
+	rows, err := m.DB.Query(query, afterID, productID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*ReviewEvent
+	for rows.Next() {
+		var event ReviewEvent
+		err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&event.ProductID,
+			&event.ReviewID,
+			&event.Data,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
+
+// LastID returns the ID of the newest event, or zero if the log is empty.
+func (m ReviewEventModel) LastID() (int64, error) {
+	query := `
+        SELECT COALESCE(MAX(id), 0)
+        FROM review_events`
+
+	var id int64
+	err := m.DB.QueryRow(query).Scan(&id)
+	return id, err
+}
+
+// DeleteBefore removes events created before cutoff.
+func (m ReviewEventModel) DeleteBefore(cutoff time.Time) error {
+	query := `
+        DELETE FROM review_events
+        WHERE created_at < $1`
+
+	_, err := m.DB.Exec(query, cutoff)
+	return err
+}
@@ -0,0 +1,52 @@
+// internal/data/export.go
+package data
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// exportBatchSize is how many rows are fetched from the cursor per round trip.
+const exportBatchSize = 500
+
+// streamCursor runs query through a server-side cursor inside a read-only
+// transaction and calls scan for every row, fetching exportBatchSize rows
+// at a time so the full result set is never held in memory.
+func streamCursor(ctx context.Context, db DBTX, query string, args []interface{}, scan func(*sql.Rows) error) error {
+	return withTx(ctx, db, &sql.TxOptions{ReadOnly: true}, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...)
+		if err != nil {
+			return err
+		}
+
+		fetch := fmt.Sprintf("FETCH FORWARD %d FROM export_cursor", exportBatchSize)
+		for {
+			rows, err := tx.QueryContext(ctx, fetch)
+			if err != nil {
+				return err
+			}
+
+			count := 0
+			for rows.Next() {
+				count++
+				err = scan(rows)
+				if err != nil {
+					rows.Close()
+					return err
+				}
+			}
+			err = rows.Err()
+			rows.Close()
+			if err != nil {
+				return err
+			}
+
+			if count < exportBatchSize {
+				// close explicitly in case we are joined to a longer transaction
+				_, err = tx.ExecContext(ctx, "CLOSE export_cursor")
+				return err
+			}
+		}
+	})
+}
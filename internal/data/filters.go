@@ -11,6 +11,10 @@ type Filters struct {
 	Sort   string
 	Limit  int
 	Offset int
+	// MinPrice, MaxPrice and InStock narrow product lists; nil means no filter.
+	MinPrice *int64
+	MaxPrice *int64
+	InStock  *bool
 }
 
 // ValidateFilter ensures that the provided filter values are within acceptable bounds.
@@ -38,16 +42,33 @@ func (f *Filters) SortColumn() string {
 		return "average_rating"
 	case "date":
 		return "created_at"
+	case "price":
+		return "price"
 	default:
 		return "created_at"
 	}
 }
 
-// ValidateSort checks if the sort parameter is valid using the Validator.
-func (f *Filters) ValidateSort(v *validator.Validator) {
-	validSorts := map[string]bool{
-		"rating": true,
-		"date":   true,
+// SortDirection returns the SQL sort direction for the sort parameter.
+// Prices sort cheapest first; everything else newest or highest first.
+func (f *Filters) SortDirection() string {
+	if f.Sort == "price" {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// ProductSortSafelist lists the accepted values for sorting products.
+var ProductSortSafelist = []string{"rating", "date", "price"}
+
+// ReviewSortSafelist lists the accepted values for sorting reviews.
+var ReviewSortSafelist = []string{"helpful", "date"}
+
+// ValidateSort checks the sort parameter is one of safelist using the Validator.
+func (f *Filters) ValidateSort(v *validator.Validator, safelist []string) {
+	validSorts := make(map[string]bool)
+	for _, sort := range safelist {
+		validSorts[sort] = true
 	}
 
 	// Check if the Sort field is empty or invalid
@@ -56,11 +77,20 @@ func (f *Filters) ValidateSort(v *validator.Validator) {
 	}
 }
 
+// ValidatePriceRange checks the price filters are non-negative and in order.
+func (f *Filters) ValidatePriceRange(v *validator.Validator) {
+	v.Check(f.MinPrice == nil || *f.MinPrice >= 0, "min_price", "must not be negative")
+	v.Check(f.MaxPrice == nil || *f.MaxPrice >= 0, "max_price", "must not be negative")
+	if f.MinPrice != nil && f.MaxPrice != nil {
+		v.Check(*f.MinPrice <= *f.MaxPrice, "max_price", "must not be less than min_price")
+	}
+}
+
 // BuildQuery appends sorting, limit, and offset to a base query.
 func (f *Filters) BuildQuery(baseQuery string) string {
 	// Apply default values to filter fields
 	f.ValidateFilter()
 
 	// Append sorting, limit, and offset clauses to the base query
-	return fmt.Sprintf("%s ORDER BY %s DESC LIMIT %d OFFSET %d", baseQuery, f.SortColumn(), f.Limit, f.Offset)
+	return fmt.Sprintf("%s ORDER BY %s %s LIMIT %d OFFSET %d", baseQuery, f.SortColumn(), f.SortDirection(), f.Limit, f.Offset)
 }
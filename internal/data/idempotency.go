@@ -0,0 +1,109 @@
+// internal/data/idempotency.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrIdempotencyKeyExists is returned by Reserve when the key is already taken.
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+// IdempotencyKey records a request made with an Idempotency-Key header
+// and, once the handler has finished, the response that was sent for it.
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+	Status      int // zero while the original request is still in flight
+	ContentType string
+	Location    string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+type IdempotencyModel struct {
+	DB DBTX
+}
+
+// Get retrieves an unexpired key. It returns nil and no error if the key
+// is unknown or has expired.
+func (m IdempotencyModel) Get(key string) (*IdempotencyKey, error) {
+	query := `
+        SELECT key, fingerprint, COALESCE(status, 0), COALESCE(content_type, ''),
+               COALESCE(location, ''), body, created_at, expires_at
+        FROM idempotency_keys
+        WHERE key = $1 AND expires_at > NOW()`
+
+	var k IdempotencyKey
+	err := m.DB.QueryRow(query, key).Scan(
+		&k.Key,
+		&k.Fingerprint,
+		&k.Status,
+		&k.ContentType,
+		&k.Location,
+		&k.Body,
+		&k.CreatedAt,
+		&k.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+// Reserve claims a key for an in-flight request. An expired row with the
+// same key is replaced. ErrIdempotencyKeyExists means another request
+// claimed the key first.
+func (m IdempotencyModel) Reserve(key *IdempotencyKey, ttl time.Duration) error {
+	query := `
+        INSERT INTO idempotency_keys (key, fingerprint, expires_at)
+        VALUES ($1, $2, NOW() + make_interval(secs => $3))
+        ON CONFLICT (key) DO UPDATE
+        SET fingerprint = EXCLUDED.fingerprint, status = NULL, content_type = NULL,
+            location = NULL, body = NULL, created_at = NOW(), expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= NOW()
+        RETURNING created_at, expires_at`
+
+	err := m.DB.QueryRow(query, key.Key, key.Fingerprint, ttl.Seconds()).Scan(&key.CreatedAt, &key.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return ErrIdempotencyKeyExists
+	}
+	return err
+}
+
+// Complete stores the response that was sent for a reserved key.
+func (m IdempotencyModel) Complete(key *IdempotencyKey) error {
+	query := `
+        UPDATE idempotency_keys
+        SET status = $1, content_type = $2, location = $3, body = $4
+        WHERE key = $5`
+
+	args := []interface{}{key.Status, key.ContentType, key.Location, key.Body, key.Key}
+	_, err := m.DB.Exec(query, args...)
+	return err
+}
+
+// Delete releases a key so the request can be retried.
+func (m IdempotencyModel) Delete(key string) error {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE key = $1`
+
+	_, err := m.DB.Exec(query, key)
+	return err
+}
+
+// DeleteExpired removes every key past its expiry time.
+func (m IdempotencyModel) DeleteExpired() error {
+	query := `
+        DELETE FROM idempotency_keys
+        WHERE expires_at <= NOW()`
+
+	_, err := m.DB.Exec(query)
+	return err
+}
@@ -0,0 +1,244 @@
+// internal/data/images.go
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrImageOrder is returned by Reorder when the IDs given aren't exactly
+// the product's images.
+var ErrImageOrder = errors.New("order must list each of the product's images once")
+
+// ImageVariant is a resized copy of a product image.
+type ImageVariant struct {
+	Size   int    `json:"size"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+// ProductImage is an uploaded picture of a product. A product's images are
+// kept in the order given by Position, starting at zero.
+type ProductImage struct {
+	ID          int64          `json:"id"`
+	ProductID   int64          `json:"product_id"`
+	Position    int            `json:"position"`
+	ContentType string         `json:"content_type"`
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	Size        int64          `json:"size"`
+	URL         string         `json:"url"`
+	Variants    []ImageVariant `json:"variants"`
+	Key         string         `json:"-"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// VariantKey is the storage key of an image's variant of the given size,
+// e.g. products/4/ab12.jpg -> products/4/ab12_480.jpg
+func VariantKey(key string, size int) string {
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + strconv.Itoa(size) + ext
+}
+
+// Keys returns the storage keys of the image and all its variants.
+func (i *ProductImage) Keys() []string {
+	keys := []string{i.Key}
+	for _, variant := range i.Variants {
+		keys = append(keys, VariantKey(i.Key, variant.Size))
+	}
+	return keys
+}
+
+type ProductImageModel struct {
+	DB DBTX
+}
+
+// Insert adds an image after the product's existing ones.
+func (m ProductImageModel) Insert(image *ProductImage) error {
+	variants, err := json.Marshal(image.Variants)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO product_images (product_id, position, content_type, width, height, size, url, variants, key)
+        VALUES ($1, (SELECT COUNT(*) FROM product_images WHERE product_id = $1), $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, position, created_at`
+
+	args := []interface{}{image.ProductID, image.ContentType, image.Width, image.Height, image.Size,
+		image.URL, variants, image.Key}
+	return m.DB.QueryRow(query, args...).Scan(&image.ID, &image.Position, &image.CreatedAt)
+}
+
+// Get retrieves an image by ID.
+func (m ProductImageModel) Get(id int64) (*ProductImage, error) {
+	query := `
+        SELECT id, product_id, position, content_type, width, height, size, url, variants, key, created_at
+        FROM product_images
+        WHERE id = $1`
+
+	image, err := scanProductImage(m.DB.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("image not found")
+	}
+	return image, err
+}
+
+// ForProduct returns a product's images in order.
+func (m ProductImageModel) ForProduct(productID int64) ([]*ProductImage, error) {
+	images, err := m.forProducts([]int64{productID})
+	if err != nil {
+		return nil, err
+	}
+	return images[productID], nil
+}
+
+// Delete removes an image's record and closes the gap it leaves in the
+// order. The caller deletes its files.
+func (m ProductImageModel) Delete(image *ProductImage) error {
+	query := `
+        WITH deleted AS (
+            DELETE FROM product_images
+            WHERE id = $1
+        )
+        UPDATE product_images
+        SET position = position - 1
+        WHERE product_id = $2 AND position > $3`
+
+	_, err := m.DB.Exec(query, image.ID, image.ProductID, image.Position)
+	return err
+}
+
+// Reorder puts a product's images in the order of ids, which must name
+// every one of its images exactly once.
+func (m ProductImageModel) Reorder(productID int64, ids []int64) error {
+	images, err := m.ForProduct(productID)
+	if err != nil {
+		return err
+	}
+	if len(ids) != len(images) {
+		return ErrImageOrder
+	}
+	for _, image := range images {
+		if !slices.Contains(ids, image.ID) {
+			return ErrImageOrder
+		}
+	}
+
+	query := `
+        UPDATE product_images i
+        SET position = o.position - 1
+        FROM unnest($1::bigint[]) WITH ORDINALITY AS o(id, position)
+        WHERE i.id = o.id AND i.product_id = $2`
+
+	_, err = m.DB.Exec(query, pq.Array(ids), productID)
+	return err
+}
+
+// PurgeDeleted removes the images of products deleted before cutoff and
+// returns their storage keys, so the files can be deleted before
+// ProductModel.PurgeDeleted removes the products.
+func (m ProductImageModel) PurgeDeleted(cutoff time.Time) ([]string, error) {
+	query := `
+        DELETE FROM product_images i
+        USING products p
+        WHERE i.product_id = p.id AND p.deleted_at < $1
+        RETURNING i.id, i.product_id, i.position, i.content_type, i.width, i.height, i.size, i.url, i.variants, i.key, i.created_at`
+
+	rows, err := m.DB.Query(query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		image, err := scanProductImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, image.Keys()...)
+	}
+
+	return keys, rows.Err()
+}
+
+// forProducts loads the images of several products in one query, keyed by
+// product ID and in order
+func (m ProductImageModel) forProducts(ids []int64) (map[int64][]*ProductImage, error) {
+	query := `
+        SELECT id, product_id, position, content_type, width, height, size, url, variants, key, created_at
+        FROM product_images
+        WHERE product_id = ANY($1)
+        ORDER BY product_id, position`
+
+	rows, err := m.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := make(map[int64][]*ProductImage)
+	for rows.Next() {
+		image, err := scanProductImage(rows)
+		if err != nil {
+			return nil, err
+		}
+		images[image.ProductID] = append(images[image.ProductID], image)
+	}
+
+	return images, rows.Err()
+}
+
+func scanProductImage(row interface{ Scan(...any) error }) (*ProductImage, error) {
+	var image ProductImage
+	var variants []byte
+	err := row.Scan(
+		&image.ID,
+		&image.ProductID,
+		&image.Position,
+		&image.ContentType,
+		&image.Width,
+		&image.Height,
+		&image.Size,
+		&image.URL,
+		&variants,
+		&image.Key,
+		&image.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &image, json.Unmarshal(variants, &image.Variants)
+}
+
+// attachImages sets the Images of products from one query.
+func (m ProductModel) attachImages(products ...*Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+
+	images, err := ProductImageModel{DB: m.DB}.forProducts(ids)
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		product.Images = images[product.ID]
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+// internal/data/import.go
+package data
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// ImportBatchSize is the number of products written per COPY batch.
+const ImportBatchSize = 1000
+
+// BulkInsert writes products in a single transaction, or in the caller's
+// when the model is bound to one, by COPYing them into a temporary staging
+// table and moving them into products from there. With upsert set, rows
+// whose external_id already exists are updated in place instead of
+// inserted, unless the product has been deleted; those rows are skipped
+// and left out of both counts. It reports how many rows were inserted and
+// how many were updated. Each update is recorded as a revision made by
+// userID.
+func (m ProductModel) BulkInsert(products []*Product, upsert bool, userID int64) (int, int, error) {
+	inserted, updated := 0, 0
+	err := withTx(context.Background(), m.DB, nil, func(tx *sql.Tx) error {
+		var err error
+		inserted, updated, err = bulkInsert(tx, products, upsert, userID)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return inserted, updated, nil
+}
+
+// ExistingExternalIDs reports which of ids already belong to a product,
+// mapping each to whether that product has been deleted. Deleted products
+// count, since they still hold their external_id.
+func (m ProductModel) ExistingExternalIDs(ids []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	query := `
+        SELECT external_id, deleted_at IS NOT NULL
+        FROM products
+        WHERE external_id = ANY($1)`
+
+	rows, err := m.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var deleted bool
+		err = rows.Scan(&id, &deleted)
+		if err != nil {
+			return nil, err
+		}
+		existing[id] = deleted
+	}
+	return existing, rows.Err()
+}
+
+func bulkInsert(tx *sql.Tx, products []*Product, upsert bool, userID int64) (int, int, error) {
+	_, err := tx.Exec(`
+        CREATE TEMPORARY TABLE products_import (
+            name VARCHAR(100),
+            description TEXT,
+            category VARCHAR(50),
+            image_url TEXT,
+            external_id VARCHAR(100),
+            price BIGINT,
+            currency CHAR(3),
+            availability TEXT
+        )`)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("products_import", "name", "description", "category", "image_url", "external_id",
+		"price", "currency", "availability"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, product := range products {
+		if product.Currency == "" {
+			product.Currency = DefaultCurrency
+		}
+		if product.Availability == "" {
+			product.Availability = AvailabilityInStock
+		}
+		var externalID interface{}
+		if product.ExternalID != "" {
+			externalID = product.ExternalID
+		}
+		_, err = stmt.Exec(product.Name, product.Description, product.Category, product.ImageURL, externalID,
+			product.Price, product.Currency, product.Availability)
+		if err != nil {
+			stmt.Close()
+			return 0, 0, err
+		}
+	}
+
+	// an Exec with no arguments flushes the COPY buffer
+	_, err = stmt.Exec()
+	if err != nil {
+		stmt.Close()
+		return 0, 0, err
+	}
+	err = stmt.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if upsert {
+		err = recordImportRevisions(tx, userID)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	query := `
+        INSERT INTO products (name, description, category, image_url, external_id, price, currency, availability)
+        SELECT name, description, category, image_url, external_id, price, currency, availability
+        FROM products_import`
+	if upsert {
+		query += `
+        ON CONFLICT (external_id) DO UPDATE
+        SET name = EXCLUDED.name, description = EXCLUDED.description,
+            category = EXCLUDED.category, image_url = EXCLUDED.image_url,
+            price = EXCLUDED.price, currency = EXCLUDED.currency,
+            availability = EXCLUDED.availability, updated_at = NOW()
+        WHERE products.deleted_at IS NULL`
+	}
+	// xmax is zero for freshly inserted rows and non-zero for updated ones
+	query += `
+        RETURNING (xmax = 0)`
+
+	rows, err := tx.Query(query)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	inserted, updated := 0, 0
+	for rows.Next() {
+		var wasInsert bool
+		err = rows.Scan(&wasInsert)
+		if err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		if wasInsert {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// dropped explicitly rather than ON COMMIT so a longer transaction can import twice
+	_, err = tx.Exec("DROP TABLE products_import")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return inserted, updated, nil
+}
+
+// recordImportRevisions records a revision for every staged row that will
+// change an existing product, before the upsert overwrites it. The diff is
+// worked out the same way as RevisionModel.Record, over the fields an
+// import can change.
+func recordImportRevisions(tx *sql.Tx, userID int64) error {
+	query := `
+        INSERT INTO revisions (kind, record_id, user_id, previous, changes)
+        SELECT 'product', p.id, NULLIF($1, 0),
+               jsonb_strip_nulls(jsonb_build_object(
+                   'id', p.id, 'name', p.name, 'description', NULLIF(p.description, ''),
+                   'category', COALESCE(p.category, ''), 'image_url', COALESCE(p.image_url, ''),
+                   'external_id', p.external_id, 'average_rating', COALESCE(p.average_rating, 0),
+                   'price', p.price, 'currency', p.currency, 'availability', p.availability
+               )),
+               diff.changes
+        FROM products_import i
+        JOIN products p ON p.external_id = i.external_id AND p.deleted_at IS NULL
+        CROSS JOIN LATERAL (
+            SELECT jsonb_object_agg(old.key, jsonb_build_object('from', old.value, 'to', new.value)) AS changes
+            FROM jsonb_each(jsonb_build_object(
+                'name', p.name, 'description', COALESCE(p.description, ''),
+                'category', COALESCE(p.category, ''), 'image_url', COALESCE(p.image_url, ''),
+                'price', p.price, 'currency', p.currency, 'availability', p.availability
+            )) old
+            JOIN jsonb_each(jsonb_build_object(
+                'name', i.name, 'description', COALESCE(i.description, ''),
+                'category', COALESCE(i.category, ''), 'image_url', COALESCE(i.image_url, ''),
+                'price', i.price, 'currency', i.currency, 'availability', i.availability
+            )) new ON new.key = old.key
+            WHERE old.value <> new.value
+        ) diff
+        WHERE diff.changes IS NOT NULL`
+
+	_, err := tx.Exec(query, userID)
+	return err
+}
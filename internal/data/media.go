@@ -0,0 +1,176 @@
+// internal/data/media.go
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ReviewMedia is an image attached to a review. The files themselves live
+// in the storage backend; the keys locate them there and the URLs are
+// where clients fetch them.
+type ReviewMedia struct {
+	ID           int64     `json:"id"`
+	ReviewID     int64     `json:"review_id"`
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	Key          string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ReviewMediaModel struct {
+	DB DBTX
+}
+
+// Insert records an attachment whose files have already been stored.
+func (m ReviewMediaModel) Insert(media *ReviewMedia) error {
+	query := `
+        INSERT INTO review_media (review_id, content_type, width, height, size, url, thumbnail_url, key, thumbnail_key)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id, created_at`
+
+	args := []interface{}{media.ReviewID, media.ContentType, media.Width, media.Height, media.Size,
+		media.URL, media.ThumbnailURL, media.Key, media.ThumbnailKey}
+	return m.DB.QueryRow(query, args...).Scan(&media.ID, &media.CreatedAt)
+}
+
+// Get retrieves an attachment by ID.
+func (m ReviewMediaModel) Get(id int64) (*ReviewMedia, error) {
+	query := `
+        SELECT id, review_id, content_type, width, height, size, url, thumbnail_url, key, thumbnail_key, created_at
+        FROM review_media
+        WHERE id = $1`
+
+	var media ReviewMedia
+	err := m.DB.QueryRow(query, id).Scan(
+		&media.ID,
+		&media.ReviewID,
+		&media.ContentType,
+		&media.Width,
+		&media.Height,
+		&media.Size,
+		&media.URL,
+		&media.ThumbnailURL,
+		&media.Key,
+		&media.ThumbnailKey,
+		&media.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("media not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &media, nil
+}
+
+// CountForReview returns the number of attachments a review has.
+func (m ReviewMediaModel) CountForReview(reviewID int64) (int, error) {
+	query := `
+        SELECT COUNT(*)
+        FROM review_media
+        WHERE review_id = $1`
+
+	var count int
+	err := m.DB.QueryRow(query, reviewID).Scan(&count)
+	return count, err
+}
+
+// Delete removes an attachment's record. The caller deletes its files.
+func (m ReviewMediaModel) Delete(id int64) error {
+	query := `
+        DELETE FROM review_media
+        WHERE id = $1`
+
+	_, err := m.DB.Exec(query, id)
+	return err
+}
+
+// PurgeDeleted removes the attachments of reviews deleted before cutoff
+// and returns the storage keys of their files, so they can be deleted
+// before ReviewModel.PurgeDeleted removes the reviews.
+func (m ReviewMediaModel) PurgeDeleted(cutoff time.Time) ([]string, error) {
+	query := `
+        DELETE FROM review_media rm
+        USING reviews r
+        WHERE rm.review_id = r.id AND r.deleted_at < $1
+        RETURNING rm.key, rm.thumbnail_key`
+
+	rows, err := m.DB.Query(query, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key, thumbnailKey string
+		err := rows.Scan(&key, &thumbnailKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key, thumbnailKey)
+	}
+
+	return keys, rows.Err()
+}
+
+// attachMedia loads the attachments of reviews in one query and sets each
+// review's Media, oldest first.
+func (m ReviewModel) attachMedia(reviews ...*Review) error {
+	if len(reviews) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Review, len(reviews))
+	ids := make([]int64, 0, len(reviews))
+	for _, review := range reviews {
+		byID[review.ID] = review
+		ids = append(ids, review.ID)
+	}
+
+	query := `
+        SELECT id, review_id, content_type, width, height, size, url, thumbnail_url, key, thumbnail_key, created_at
+        FROM review_media
+        WHERE review_id = ANY($1)
+        ORDER BY id`
+
+	rows, err := m.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var media ReviewMedia
+		err := rows.Scan(
+			&media.ID,
+			&media.ReviewID,
+			&media.ContentType,
+			&media.Width,
+			&media.Height,
+			&media.Size,
+			&media.URL,
+			&media.ThumbnailURL,
+			&media.Key,
+			&media.ThumbnailKey,
+			&media.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		review := byID[media.ReviewID]
+		review.Media = append(review.Media, &media)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,118 @@
+// internal/data/moderation.go
+package data
+
+import (
+	"fmt"
+
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Review moderation statuses. Only approved reviews are public and count
+// towards a product's average rating.
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+	ReviewHidden   = "hidden"
+)
+
+// ReviewStatuses lists every review status.
+var ReviewStatuses = []string{ReviewPending, ReviewApproved, ReviewRejected, ReviewHidden}
+
+// reviewTransitions lists the statuses a moderator may move a review to
+// from each status.
+var reviewTransitions = map[string][]string{
+	ReviewPending:  {ReviewApproved, ReviewRejected},
+	ReviewApproved: {ReviewHidden, ReviewRejected},
+	ReviewHidden:   {ReviewApproved, ReviewRejected},
+	ReviewRejected: {ReviewApproved},
+}
+
+// ValidateReviewTransition checks a moderation decision. Rejecting or
+// hiding a review needs a reason the author can be shown.
+func ValidateReviewTransition(v *validator.Validator, from string, to string, reason string) {
+	allowed := false
+	for _, status := range reviewTransitions[from] {
+		if status == to {
+			allowed = true
+		}
+	}
+	v.Check(to != "", "status", "must be provided")
+	v.Check(to == "" || allowed, "status", fmt.Sprintf("cannot move a review from %s to %s", from, to))
+	v.Check(to == ReviewApproved || to == "" || reason != "", "reason", "must be provided when rejecting or hiding a review")
+	v.Check(len(reason) <= 500, "reason", "must not be more than 500 characters")
+}
+
+// Queue retrieves reviews in one status for moderators, oldest first.
+// A productID of zero matches every product.
+func (m ReviewModel) Queue(status string, productID int64, filters Filters) ([]*Review, error) {
+	filters.ValidateFilter()
+
+	query := `
+        SELECT id, product_id, COALESCE(user_id, 0), COALESCE(variant_id, 0), content, author, rating, helpful_count, created_at, updated_at, status,
+               COALESCE(moderation_reason, ''), moderated_at, screening_score, screening_flags, edited
+        FROM reviews
+        WHERE status = $1 AND (product_id = $2 OR $2 = 0) AND deleted_at IS NULL
+        ORDER BY created_at, id
+        LIMIT $3 OFFSET $4`
+
+	rows, err := m.DB.Query(query, status, productID, filters.Limit, filters.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []*Review
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&review.ID,
+			&review.ProductID,
+			&review.UserID,
+			&review.VariantID,
+			&review.Content,
+			&review.Author,
+			&review.Rating,
+			&review.HelpfulCount,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Status,
+			&review.ModerationReason,
+			&review.ModeratedAt,
+			&review.ScreeningScore,
+			pq.Array(&review.ScreeningFlags),
+			&review.Edited,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, &review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// moderators need to see the photos as well as the text
+	err = m.attachMedia(reviews...)
+	if err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// Moderate records a moderator's decision on review, which must already
+// carry the new Status and ModerationReason. A moderatorID of zero records
+// an automatic decision.
+func (m ReviewModel) Moderate(review *Review, moderatorID int64) error {
+	query := `
+        UPDATE reviews
+        SET status = $1, moderation_reason = NULLIF($2, ''), moderated_by = NULLIF($3, 0), moderated_at = NOW()
+        WHERE id = $4
+        RETURNING moderated_at`
+
+	args := []interface{}{review.Status, review.ModerationReason, moderatorID, review.ID}
+	err := m.DB.QueryRow(query, args...).Scan(&review.ModeratedAt)
+	return duplicateReviewError(err)
+}
@@ -0,0 +1,104 @@
+// internal/data/outbox.go
+package data
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Catalogue event types. Review event types are defined with ReviewEvent.
+const (
+	EventProductCreated   = "product.created"
+	EventProductUpdated   = "product.updated"
+	EventProductDeleted   = "product.deleted"
+	EventProductRestored  = "product.restored"
+	EventProductsImported = "products.imported"
+	EventVariantCreated   = "variant.created"
+	EventVariantUpdated   = "variant.updated"
+	EventVariantDeleted   = "variant.deleted"
+)
+
+// OutboxEventTypes lists every event type that can be written to the
+// outbox and subscribed to by a webhook.
+var OutboxEventTypes = []string{
+	EventProductCreated,
+	EventProductUpdated,
+	EventProductDeleted,
+	EventProductRestored,
+	EventProductsImported,
+	EventVariantCreated,
+	EventVariantUpdated,
+	EventVariantDeleted,
+	EventReviewCreated,
+	EventReviewUpdated,
+	EventReviewDeleted,
+	EventReviewRestored,
+	EventReviewModerated,
+	EventRatingChanged,
+}
+
+// OutboxModel writes events to the outbox table. Inserting in the same
+// transaction as the change it describes means an event is recorded if
+// and only if the change is committed.
+type OutboxModel struct {
+	DB DBTX
+}
+
+// Insert adds an event to the outbox, marshalling payload as its body.
+func (m OutboxModel) Insert(eventType string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO outbox (type, payload)
+        VALUES ($1, $2)`
+
+	_, err = m.DB.Exec(query, eventType, body)
+	return err
+}
+
+// FanOut takes up to limit undispatched events, creates a pending delivery
+// for every active webhook subscribed to each one and marks the events as
+// dispatched, all in one statement. It returns the number of events taken.
+func (m OutboxModel) FanOut(limit int) (int64, error) {
+	query := `
+        WITH batch AS (
+            SELECT id, type
+            FROM outbox
+            WHERE dispatched_at IS NULL
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        ), fanned AS (
+            INSERT INTO webhook_deliveries (webhook_id, outbox_id)
+            SELECT w.id, b.id
+            FROM batch b
+            JOIN webhooks w ON w.active AND (b.type = ANY(w.events) OR '*' = ANY(w.events))
+        )
+        UPDATE outbox
+        SET dispatched_at = NOW()
+        WHERE id IN (SELECT id FROM batch)`
+
+	result, err := m.DB.Exec(query, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteDispatchedBefore removes dispatched events older than cutoff once
+// none of their deliveries are pending or dead.
+func (m OutboxModel) DeleteDispatchedBefore(cutoff time.Time) error {
+	query := `
+        DELETE FROM outbox o
+        WHERE o.dispatched_at < $1
+          AND NOT EXISTS (
+              SELECT 1 FROM webhook_deliveries d
+              WHERE d.outbox_id = o.id AND d.status <> 'delivered'
+          )`
+
+	_, err := m.DB.Exec(query, cutoff)
+	return err
+}
@@ -2,11 +2,16 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
 	"time"
 
 	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
 )
 
 type Product struct {
@@ -15,40 +20,142 @@ type Product struct {
 	Description   string    `json:"description,omitempty"`
 	Category      string    `json:"category"`
 	ImageURL      string    `json:"image_url"`
+	ExternalID    string    `json:"external_id,omitempty"`
 	AverageRating float32   `json:"average_rating"`
-	CreatedAt     time.Time `json:"-"`
-	UpdatedAt     time.Time `json:"-"`
+	Status        string    `json:"status"`
+	// Price is in minor units of Currency, e.g. 1999 for USD 19.99
+	Price        int64  `json:"price"`
+	Currency     string `json:"currency"`
+	Availability string `json:"availability"`
+	// Images are the product's uploaded pictures in order. ImageURL can
+	// still point at a picture hosted elsewhere.
+	Images    []*ProductImage `json:"images,omitempty"`
+	CreatedAt time.Time       `json:"-"`
+	UpdatedAt time.Time       `json:"-"`
+
+	// storedStatus is the status as last read from or written to the
+	// database, which ValidateProduct checks the transition from
+	storedStatus string
+}
+
+// Product statuses. Drafts are only visible to editors and archived
+// products are left out of listings but can still be fetched by ID.
+const (
+	ProductDraft    = "draft"
+	ProductActive   = "active"
+	ProductArchived = "archived"
+)
+
+// ProductStatuses lists every status a product can be in.
+var ProductStatuses = []string{ProductDraft, ProductActive, ProductArchived}
+
+// Product availabilities. Only in-stock products match the in_stock filter.
+const (
+	AvailabilityInStock      = "in_stock"
+	AvailabilityOutOfStock   = "out_of_stock"
+	AvailabilityPreorder     = "preorder"
+	AvailabilityDiscontinued = "discontinued"
+)
+
+// ProductAvailabilities lists every availability a product can have.
+var ProductAvailabilities = []string{AvailabilityInStock, AvailabilityOutOfStock, AvailabilityPreorder, AvailabilityDiscontinued}
+
+// DefaultCurrency is the currency of a new product that doesn't give one.
+const DefaultCurrency = "USD"
+
+// CurrencyRX matches an ISO 4217 currency code.
+var CurrencyRX = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// productTransitions lists the statuses a product can move to from each
+// status. A product never goes back to being a draft once it is published.
+var productTransitions = map[string][]string{
+	"":              {ProductDraft, ProductActive},
+	ProductDraft:    {ProductActive, ProductArchived},
+	ProductActive:   {ProductArchived},
+	ProductArchived: {ProductActive},
 }
 
 type ProductModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func ValidateProduct(v *validator.Validator, product *Product) {
 	v.Check(product.Name != "", "name", "must be provided")
 	v.Check(len(product.Name) <= 100, "name", "must not be more than 100 characters")
 	v.Check(product.Category != "", "category", "must be provided")
-	v.Check(product.ImageURL != "", "image_url", "must be a valid URL")
+	// an uploaded image can stand in for the image URL
+	v.Check(product.ImageURL != "" || len(product.Images) > 0, "image_url", "must be provided unless the product has uploaded images")
+	v.Check(len(product.Category) <= 50, "category", "must not be more than 50 characters")
+	if product.ImageURL != "" {
+		u, err := url.Parse(product.ImageURL)
+		v.Check(len(product.ImageURL) <= 2048, "image_url", "must not be more than 2048 characters")
+		v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "image_url", "must be an absolute http or https URL")
+	}
+	v.Check(len(product.ExternalID) <= 100, "external_id", "must not be more than 100 characters")
+	validateProductStatus(v, product.storedStatus, product.Status)
+	v.Check(product.Price >= 0, "price", "must not be negative")
+	// a new product can leave out its currency and availability to take the defaults
+	if product.ID != 0 || product.Currency != "" {
+		v.Check(CurrencyRX.MatchString(product.Currency), "currency", "must be a three-letter ISO 4217 code such as USD")
+	}
+	if product.ID != 0 || product.Availability != "" {
+		v.Check(slices.Contains(ProductAvailabilities, product.Availability), "availability", "must be in_stock, out_of_stock, preorder or discontinued")
+	}
 }
 
-// Insert adds a new product to the database.
+// validateProductStatus checks status is known and can be reached from
+// the stored status. An empty status on a new product means active.
+func validateProductStatus(v *validator.Validator, stored string, status string) {
+	if status == "" && stored == "" {
+		return
+	}
+	switch {
+	case !slices.Contains(ProductStatuses, status):
+		v.AddError("status", "must be draft, active or archived")
+	case stored == "":
+		v.Check(slices.Contains(productTransitions[stored], status), "status", "must be draft or active for a new product")
+	case status != stored:
+		v.Check(slices.Contains(productTransitions[stored], status), "status", fmt.Sprintf("cannot change from %s to %s", stored, status))
+	}
+}
+
+// Insert adds a new product to the database. A product without a status
+// is active, and one without a currency or availability takes
+// DefaultCurrency and is in stock.
 func (m ProductModel) Insert(product *Product) error {
+	if product.Status == "" {
+		product.Status = ProductActive
+	}
+	if product.Currency == "" {
+		product.Currency = DefaultCurrency
+	}
+	if product.Availability == "" {
+		product.Availability = AvailabilityInStock
+	}
+
 	query := `
-        INSERT INTO products (name, description, category, image_url)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO products (name, description, category, image_url, external_id, status, price, currency, availability)
+        VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, $8, $9)
         RETURNING id, created_at, updated_at`
 
-	args := []interface{}{product.Name, product.Description, product.Category, product.ImageURL}
+	args := []interface{}{product.Name, product.Description, product.Category, product.ImageURL, product.ExternalID, product.Status,
+		product.Price, product.Currency, product.Availability}
 
-	return m.DB.QueryRow(query, args...).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+	err := m.DB.QueryRow(query, args...).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	product.storedStatus = product.Status
+	return nil
 }
 
 // Get retrieves a specific product by ID.
 func (m ProductModel) Get(id int64) (*Product, error) {
 	query := `
-        SELECT id, name, description, category, image_url, average_rating, created_at, updated_at
+        SELECT id, name, description, category, image_url, COALESCE(external_id, ''), average_rating, created_at, updated_at, status,
+               price, currency, availability
         FROM products
-        WHERE id = $1`
+        WHERE id = $1 AND deleted_at IS NULL`
 
 	var product Product
 	err := m.DB.QueryRow(query, id).Scan(
@@ -57,9 +164,14 @@ func (m ProductModel) Get(id int64) (*Product, error) {
 		&product.Description,
 		&product.Category,
 		&product.ImageURL,
+		&product.ExternalID,
 		&product.AverageRating,
 		&product.CreatedAt,
 		&product.UpdatedAt,
+		&product.Status,
+		&product.Price,
+		&product.Currency,
+		&product.Availability,
 	)
 
 	if err == sql.ErrNoRows {
@@ -67,6 +179,12 @@ func (m ProductModel) Get(id int64) (*Product, error) {
 	} else if err != nil {
 		return nil, err
 	}
+	product.storedStatus = product.Status
+
+	err = m.attachImages(&product)
+	if err != nil {
+		return nil, err
+	}
 
 	return &product, nil
 }
@@ -75,40 +193,102 @@ func (m ProductModel) Get(id int64) (*Product, error) {
 func (m ProductModel) Update(product *Product) error {
 	query := `
         UPDATE products
-        SET name = $1, description = $2, category = $3, image_url = $4, updated_at = NOW()
-        WHERE id = $5`
+        SET name = $1, description = $2, category = $3, image_url = $4, status = $5,
+            price = $6, currency = $7, availability = $8, updated_at = NOW()
+        WHERE id = $9 AND deleted_at IS NULL`
 
-	args := []interface{}{product.Name, product.Description, product.Category, product.ImageURL, product.ID}
+	args := []interface{}{product.Name, product.Description, product.Category, product.ImageURL, product.Status,
+		product.Price, product.Currency, product.Availability, product.ID}
 	_, err := m.DB.Exec(query, args...)
-	return err
+	if err != nil {
+		return err
+	}
+	product.storedStatus = product.Status
+	return nil
 }
 
-// Delete removes a product by ID from the database.
+// Delete soft-deletes a product and its reviews by stamping them with the
+// same deleted_at, so Restore can tell the reviews deleted along with the
+// product from ones deleted earlier. PurgeDeleted removes them for good.
 func (m ProductModel) Delete(id int64) error {
 	query := `
-        DELETE FROM products
-        WHERE id = $1`
+        WITH product AS (
+            UPDATE products
+            SET deleted_at = NOW()
+            WHERE id = $1 AND deleted_at IS NULL
+            RETURNING id, deleted_at
+        )
+        UPDATE reviews r
+        SET deleted_at = product.deleted_at
+        FROM product
+        WHERE r.product_id = product.id AND r.deleted_at IS NULL`
 
 	_, err := m.DB.Exec(query, id)
 	return err
 }
 
-// GetAll retrieves all products with optional filtering, sorting, and pagination.
+// Restore undoes Delete, bringing back the product and the reviews that
+// were deleted with it.
+func (m ProductModel) Restore(id int64) (*Product, error) {
+	query := `
+        WITH product AS (
+            UPDATE products p
+            SET deleted_at = NULL
+            FROM (SELECT id, deleted_at FROM products WHERE id = $1 AND deleted_at IS NOT NULL) deleted
+            WHERE p.id = deleted.id
+            RETURNING p.id, deleted.deleted_at
+        ), restored_reviews AS (
+            UPDATE reviews r
+            SET deleted_at = NULL
+            FROM product
+            WHERE r.product_id = product.id AND r.deleted_at = product.deleted_at
+        )
+        SELECT id FROM product`
+
+	err := m.DB.QueryRow(query, id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("product not found")
+	} else if err != nil {
+		return nil, duplicateReviewError(err)
+	}
+
+	return m.Get(id)
+}
+
+// PurgeDeleted permanently removes products deleted before cutoff, along
+// with their reviews.
+func (m ProductModel) PurgeDeleted(cutoff time.Time) error {
+	query := `
+        DELETE FROM products
+        WHERE deleted_at < $1`
+
+	_, err := m.DB.Exec(query, cutoff)
+	return err
+}
+
+// GetAll retrieves all products in one status with optional filtering, sorting, and pagination.
+// The price and stock filters in filters are skipped when nil.
 
 // internal/data/product.go
 
-func (m ProductModel) GetAll(name string, category string, filters Filters) ([]*Product, error) {
+func (m ProductModel) GetAll(name string, category string, status string, filters Filters) ([]*Product, error) {
     baseQuery := `
-        SELECT id, name, description, category, image_url, average_rating, created_at, updated_at
+        SELECT id, name, description, category, image_url, COALESCE(external_id, ''), average_rating, created_at, updated_at, status,
+               price, currency, availability
         FROM products
         WHERE ($1 = '%%' OR LOWER(name) LIKE LOWER($1))
           AND ($2 = '' OR category = $2)
+          AND status = $3
+          AND ($4::bigint IS NULL OR price >= $4)
+          AND ($5::bigint IS NULL OR price <= $5)
+          AND ($6::boolean IS NULL OR (availability = 'in_stock') = $6)
+          AND deleted_at IS NULL
     `
     
     // Use the BuildQuery method to add sorting, limit, and offset to the query
     query := filters.BuildQuery(baseQuery)
 
-    args := []interface{}{"%" + name + "%", category}
+    args := []interface{}{"%" + name + "%", category, status, filters.MinPrice, filters.MaxPrice, filters.InStock}
 
     rows, err := m.DB.Query(query, args...)
     if err != nil {
@@ -125,30 +305,242 @@ func (m ProductModel) GetAll(name string, category string, filters Filters) ([]*
             &product.Description,
             &product.Category,
             &product.ImageURL,
+            &product.ExternalID,
             &product.AverageRating,
             &product.CreatedAt,
             &product.UpdatedAt,
+            &product.Status,
+            &product.Price,
+            &product.Currency,
+            &product.Availability,
         )
         if err != nil {
             return nil, err
         }
         products = append(products, &product)
     }
+    if err := rows.Err(); err != nil {
+        return nil, err
+    }
+
+    err = m.attachImages(products...)
+    if err != nil {
+        return nil, err
+    }
 
     return products, nil
 }
 
-// UpdateAverageRating recalculates the average rating for a product based on its reviews.
-func (m ProductModel) UpdateAverageRating(productID int64) error {
+// Export streams every product in status matching the filters to fn, in
+// the same order GetAll would return them but without a limit or offset.
+func (m ProductModel) Export(ctx context.Context, name string, category string, status string, filters Filters, fn func(*Product) error) error {
+	query := fmt.Sprintf(`
+        SELECT id, name, description, category, image_url, COALESCE(external_id, ''), average_rating, created_at, updated_at, status,
+               price, currency, availability
+        FROM products
+        WHERE ($1 = '%%%%' OR LOWER(name) LIKE LOWER($1))
+          AND ($2 = '' OR category = $2)
+          AND status = $3
+          AND ($4::bigint IS NULL OR price >= $4)
+          AND ($5::bigint IS NULL OR price <= $5)
+          AND ($6::boolean IS NULL OR (availability = 'in_stock') = $6)
+          AND deleted_at IS NULL
+        ORDER BY %s %s, id`, filters.SortColumn(), filters.SortDirection())
+
+	args := []interface{}{"%" + name + "%", category, status, filters.MinPrice, filters.MaxPrice, filters.InStock}
+
+	return streamCursor(ctx, m.DB, query, args, func(rows *sql.Rows) error {
+		var product Product
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.Description,
+			&product.Category,
+			&product.ImageURL,
+			&product.ExternalID,
+			&product.AverageRating,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&product.Status,
+			&product.Price,
+			&product.Currency,
+			&product.Availability,
+		)
+		if err != nil {
+			return err
+		}
+		return fn(&product)
+	})
+}
+
+// UpdateAverageRating recalculates the average rating for a product based on its approved reviews
+// and reports the rating before and after. It returns nil if the product does not exist.
+func (m ProductModel) UpdateAverageRating(productID int64) (*RatingChange, error) {
 	query := `
-        UPDATE products
+        UPDATE products p
         SET average_rating = (
             SELECT COALESCE(AVG(rating), 0)
             FROM reviews
-            WHERE product_id = $1
+            WHERE product_id = $1 AND status = 'approved' AND deleted_at IS NULL
         )
-        WHERE id = $1`
+        FROM (SELECT id, COALESCE(average_rating, 0) AS average_rating FROM products WHERE id = $1) previous
+        WHERE p.id = previous.id
+        RETURNING previous.average_rating, p.average_rating`
 
-	_, err := m.DB.Exec(query, productID)
-	return err
+	change := &RatingChange{ProductID: productID}
+	err := m.DB.QueryRow(query, productID).Scan(&change.Previous, &change.AverageRating)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// CategoryCount is a category and the number of products in it.
+type CategoryCount struct {
+	Name         string `json:"name"`
+	ProductCount int    `json:"product_count"`
+}
+
+// GetMany retrieves the given products in one query, keyed by ID.
+func (m ProductModel) GetMany(ids []int64) (map[int64]*Product, error) {
+	query := `
+        SELECT id, name, description, category, image_url, COALESCE(external_id, ''), average_rating, created_at, updated_at, status,
+               price, currency, availability
+        FROM products
+        WHERE id = ANY($1) AND deleted_at IS NULL`
+
+	rows, err := m.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make(map[int64]*Product)
+	for rows.Next() {
+		var product Product
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.Description,
+			&product.Category,
+			&product.ImageURL,
+			&product.ExternalID,
+			&product.AverageRating,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&product.Status,
+			&product.Price,
+			&product.Currency,
+			&product.Availability,
+		)
+		if err != nil {
+			return nil, err
+		}
+		products[product.ID] = &product
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	all := make([]*Product, 0, len(products))
+	for _, product := range products {
+		all = append(all, product)
+	}
+	err = m.attachImages(all...)
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
+// Categories lists every category with its product count.
+func (m ProductModel) Categories() ([]*CategoryCount, error) {
+	query := `
+        SELECT category, COUNT(*)
+        FROM products
+        WHERE category IS NOT NULL AND category <> '' AND status = 'active' AND deleted_at IS NULL
+        GROUP BY category
+        ORDER BY category`
+
+	rows, err := m.DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []*CategoryCount
+	for rows.Next() {
+		var category CategoryCount
+		err := rows.Scan(&category.Name, &category.ProductCount)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, &category)
+	}
+
+	return categories, rows.Err()
+}
+
+// ForCategories retrieves one page of active products from each of the
+// given categories in one query, keyed by category. Only the sort, limit
+// and offset of filters apply.
+func (m ProductModel) ForCategories(categories []string, filters Filters) (map[string][]*Product, error) {
+	filters.ValidateFilter()
+
+	query := fmt.Sprintf(`
+        SELECT id, name, description, category, image_url, COALESCE(external_id, ''), average_rating, created_at, updated_at, status,
+               price, currency, availability
+        FROM (
+            SELECT *, ROW_NUMBER() OVER (PARTITION BY category ORDER BY %s %s) AS position
+            FROM products
+            WHERE category = ANY($1) AND status = 'active' AND deleted_at IS NULL
+        ) ranked
+        WHERE position > $2 AND position <= $2 + $3
+        ORDER BY category, position`, filters.SortColumn(), filters.SortDirection())
+
+	rows, err := m.DB.Query(query, pq.Array(categories), filters.Offset, filters.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := make(map[string][]*Product)
+	var all []*Product
+	for rows.Next() {
+		var product Product
+		err := rows.Scan(
+			&product.ID,
+			&product.Name,
+			&product.Description,
+			&product.Category,
+			&product.ImageURL,
+			&product.ExternalID,
+			&product.AverageRating,
+			&product.CreatedAt,
+			&product.UpdatedAt,
+			&product.Status,
+			&product.Price,
+			&product.Currency,
+			&product.Availability,
+		)
+		if err != nil {
+			return nil, err
+		}
+		product.storedStatus = product.Status
+		products[product.Category] = append(products[product.Category], &product)
+		all = append(all, &product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.attachImages(all...)
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
 }
@@ -0,0 +1,190 @@
+// internal/data/reports.go
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Reasons a review can be reported for
+const (
+	ReportSpam      = "spam"
+	ReportOffensive = "offensive"
+	ReportFake      = "fake"
+	ReportOffTopic  = "off_topic"
+	ReportOther     = "other"
+)
+
+// ReportReasons lists every reason a review can be reported for.
+var ReportReasons = []string{ReportSpam, ReportOffensive, ReportFake, ReportOffTopic, ReportOther}
+
+// ErrDuplicateReport is returned by Insert when the reporter has already reported the review.
+var ErrDuplicateReport = errors.New("duplicate report")
+
+// Report is one user's complaint about a review. Reports stay open until
+// a moderator makes a decision on the review.
+type Report struct {
+	ID         int64      `json:"id"`
+	ReviewID   int64      `json:"review_id"`
+	ReporterID int64      `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ReportSummary aggregates the open reports against one review for the
+// moderators' reports view.
+type ReportSummary struct {
+	ReviewID       int64          `json:"review_id"`
+	ProductID      int64          `json:"product_id"`
+	ReviewStatus   string         `json:"review_status"`
+	Reports        int            `json:"reports"`
+	Reasons        map[string]int `json:"reasons"`
+	FirstReportAt  time.Time      `json:"first_report_at"`
+	LatestReportAt time.Time      `json:"latest_report_at"`
+}
+
+func ValidateReport(v *validator.Validator, report *Report) {
+	v.Check(slices.Contains(ReportReasons, report.Reason), "reason", "must be spam, offensive, fake, off_topic or other")
+	v.Check(report.Reason != ReportOther || report.Details != "", "details", "must be provided when the reason is other")
+	v.Check(len(report.Details) <= 1000, "details", "must not be more than 1000 bytes long")
+}
+
+type ReportModel struct {
+	DB DBTX
+}
+
+// Insert records a report. A reporter can only report a review once.
+func (m ReportModel) Insert(report *Report) error {
+	query := `
+        INSERT INTO review_reports (review_id, reporter_id, reason, details)
+        VALUES ($1, $2, $3, NULLIF($4, ''))
+        RETURNING id, created_at`
+
+	args := []interface{}{report.ReviewID, report.ReporterID, report.Reason, report.Details}
+	err := m.DB.QueryRow(query, args...).Scan(&report.ID, &report.CreatedAt)
+
+	var pqError *pq.Error
+	if errors.As(err, &pqError) && pqError.Code == "23505" {
+		return ErrDuplicateReport
+	}
+	return err
+}
+
+// CountOpen returns the number of unresolved reports against a review.
+func (m ReportModel) CountOpen(reviewID int64) (int, error) {
+	query := `
+        SELECT COUNT(*)
+        FROM review_reports
+        WHERE review_id = $1 AND resolved_at IS NULL`
+
+	var count int
+	err := m.DB.QueryRow(query, reviewID).Scan(&count)
+	return count, err
+}
+
+// Resolve closes every open report against a review. It is called when a
+// moderator decides on the review, so the reports that led to the decision
+// no longer count towards hiding it again.
+func (m ReportModel) Resolve(reviewID int64) error {
+	query := `
+        UPDATE review_reports
+        SET resolved_at = NOW()
+        WHERE review_id = $1 AND resolved_at IS NULL`
+
+	_, err := m.DB.Exec(query, reviewID)
+	return err
+}
+
+// ForReview returns every report against a review, newest first.
+func (m ReportModel) ForReview(reviewID int64) ([]*Report, error) {
+	query := `
+        SELECT id, review_id, reporter_id, reason, COALESCE(details, ''), created_at, resolved_at
+        FROM review_reports
+        WHERE review_id = $1
+        ORDER BY created_at DESC, id DESC`
+
+	rows, err := m.DB.Query(query, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*Report
+	for rows.Next() {
+		var report Report
+		err := rows.Scan(
+			&report.ID,
+			&report.ReviewID,
+			&report.ReporterID,
+			&report.Reason,
+			&report.Details,
+			&report.CreatedAt,
+			&report.ResolvedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, &report)
+	}
+
+	return reports, rows.Err()
+}
+
+// Summaries aggregates open reports per review, most reported first. An
+// empty status matches reviews in every status.
+func (m ReportModel) Summaries(status string, filters Filters) ([]*ReportSummary, error) {
+	filters.ValidateFilter()
+
+	query := `
+        SELECT r.id, r.product_id, r.status, COUNT(*), MIN(rr.created_at), MAX(rr.created_at),
+               (SELECT json_object_agg(reason, n) FROM (
+                    SELECT reason, COUNT(*) AS n
+                    FROM review_reports
+                    WHERE review_id = r.id AND resolved_at IS NULL
+                    GROUP BY reason
+               ) reasons)
+        FROM review_reports rr
+        JOIN reviews r ON r.id = rr.review_id
+        WHERE rr.resolved_at IS NULL AND (r.status = $1 OR $1 = '') AND r.deleted_at IS NULL
+        GROUP BY r.id
+        ORDER BY COUNT(*) DESC, MAX(rr.created_at) DESC
+        LIMIT $2 OFFSET $3`
+
+	rows, err := m.DB.Query(query, status, filters.Limit, filters.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []*ReportSummary
+	for rows.Next() {
+		var summary ReportSummary
+		var reasons []byte
+		err := rows.Scan(
+			&summary.ReviewID,
+			&summary.ProductID,
+			&summary.ReviewStatus,
+			&summary.Reports,
+			&summary.FirstReportAt,
+			&summary.LatestReportAt,
+			&reasons,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(reasons, &summary.Reasons)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, &summary)
+	}
+
+	return summaries, rows.Err()
+}
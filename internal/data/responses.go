@@ -0,0 +1,144 @@
+// internal/data/responses.go
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ErrDuplicateResponse is returned by Insert when the review already has a response.
+var ErrDuplicateResponse = errors.New("duplicate response")
+
+// ReviewResponse is the seller's official public reply to a review. A
+// review has at most one.
+type ReviewResponse struct {
+	ID        int64     `json:"id"`
+	ReviewID  int64     `json:"review_id"`
+	UserID    int64     `json:"user_id,omitempty"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func ValidateReviewResponse(v *validator.Validator, response *ReviewResponse) {
+	v.Check(response.Content != "", "content", "must be provided")
+	v.Check(len(response.Content) <= 5000, "content", "must not be more than 5000 bytes long")
+}
+
+type ReviewResponseModel struct {
+	DB DBTX
+}
+
+// Insert adds the response to a review.
+func (m ReviewResponseModel) Insert(response *ReviewResponse) error {
+	query := `
+        INSERT INTO review_responses (review_id, user_id, content)
+        VALUES ($1, NULLIF($2, 0), $3)
+        RETURNING id, created_at, updated_at`
+
+	args := []interface{}{response.ReviewID, response.UserID, response.Content}
+	err := m.DB.QueryRow(query, args...).Scan(&response.ID, &response.CreatedAt, &response.UpdatedAt)
+
+	var pqError *pq.Error
+	if errors.As(err, &pqError) && pqError.Code == "23505" {
+		return ErrDuplicateResponse
+	}
+	return err
+}
+
+// GetForReview retrieves the response to a review.
+func (m ReviewResponseModel) GetForReview(reviewID int64) (*ReviewResponse, error) {
+	query := `
+        SELECT id, review_id, COALESCE(user_id, 0), content, created_at, updated_at
+        FROM review_responses
+        WHERE review_id = $1`
+
+	var response ReviewResponse
+	err := m.DB.QueryRow(query, reviewID).Scan(
+		&response.ID,
+		&response.ReviewID,
+		&response.UserID,
+		&response.Content,
+		&response.CreatedAt,
+		&response.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("response not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// Update replaces a response's content and records who wrote it.
+func (m ReviewResponseModel) Update(response *ReviewResponse) error {
+	query := `
+        UPDATE review_responses
+        SET content = $1, user_id = NULLIF($2, 0), updated_at = NOW()
+        WHERE id = $3
+        RETURNING updated_at`
+
+	args := []interface{}{response.Content, response.UserID, response.ID}
+	return m.DB.QueryRow(query, args...).Scan(&response.UpdatedAt)
+}
+
+// Delete removes a response by ID.
+func (m ReviewResponseModel) Delete(id int64) error {
+	query := `
+        DELETE FROM review_responses
+        WHERE id = $1`
+
+	_, err := m.DB.Exec(query, id)
+	return err
+}
+
+// attachResponses loads the responses to reviews in one query and sets
+// each review's Response.
+func (m ReviewModel) attachResponses(reviews ...*Review) error {
+	if len(reviews) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*Review, len(reviews))
+	ids := make([]int64, 0, len(reviews))
+	for _, review := range reviews {
+		byID[review.ID] = review
+		ids = append(ids, review.ID)
+	}
+
+	query := `
+        SELECT id, review_id, COALESCE(user_id, 0), content, created_at, updated_at
+        FROM review_responses
+        WHERE review_id = ANY($1)`
+
+	rows, err := m.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var response ReviewResponse
+		err := rows.Scan(
+			&response.ID,
+			&response.ReviewID,
+			&response.UserID,
+			&response.Content,
+			&response.CreatedAt,
+			&response.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		byID[response.ReviewID].Response = &response
+	}
+
+	return rows.Err()
+}
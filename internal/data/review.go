@@ -2,26 +2,65 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
 )
 
 type Review struct {
-	ID           int64     `json:"id"`
-	ProductID    int64     `json:"product_id"`
-	Content      string    `json:"content"`
-	Author       string    `json:"author"`
-	Rating       int       `json:"rating"`
-	HelpfulCount int       `json:"helpful_count"`
-	CreatedAt    time.Time `json:"-"`
-	UpdatedAt    time.Time `json:"-"`
+	ID               int64           `json:"id"`
+	ProductID        int64           `json:"product_id"`
+	UserID           int64           `json:"-"`
+	VariantID        int64           `json:"variant_id,omitempty"`
+	Content          string          `json:"content"`
+	Author           string          `json:"author"`
+	Rating           int             `json:"rating"`
+	HelpfulCount     int             `json:"helpful_count"`
+	Edited           bool            `json:"edited"`
+	Status           string          `json:"status"`
+	ModerationReason string          `json:"-"`
+	ModeratedAt      *time.Time      `json:"moderated_at,omitempty"`
+	ScreeningScore   float64         `json:"-"`
+	ScreeningFlags   []string        `json:"-"`
+	Response         *ReviewResponse `json:"response,omitempty"`
+	Media            []*ReviewMedia  `json:"media,omitempty"`
+	CreatedAt        time.Time       `json:"-"`
+	UpdatedAt        time.Time       `json:"-"`
 }
 
+// ModerationView is a review as moderators see it. It adds the reviewer's
+// user ID and the moderation and screening details that the public
+// representation of a review leaves out.
+type ModerationView struct {
+	*Review
+	UserID           int64    `json:"user_id,omitempty"`
+	ModerationReason string   `json:"moderation_reason,omitempty"`
+	ScreeningScore   float64  `json:"screening_score,omitempty"`
+	ScreeningFlags   []string `json:"screening_flags,omitempty"`
+}
+
+// ForModeration returns the moderators' view of review
+func (r *Review) ForModeration() *ModerationView {
+	return &ModerationView{
+		Review:           r,
+		UserID:           r.UserID,
+		ModerationReason: r.ModerationReason,
+		ScreeningScore:   r.ScreeningScore,
+		ScreeningFlags:   r.ScreeningFlags,
+	}
+}
+
+// ErrDuplicateReview is returned by Insert, Update and Moderate when the
+// author already has a review of the product that hasn't been rejected.
+var ErrDuplicateReview = errors.New("duplicate review")
+
 type ReviewModel struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func ValidateReview(v *validator.Validator, review *Review) {
@@ -30,35 +69,60 @@ func ValidateReview(v *validator.Validator, review *Review) {
 	v.Check(review.Author != "", "author", "must be provided")
 }
 
-// Insert adds a new review to the database.
+// Insert adds a new review to the database. A review without a status is pending until a moderator approves it.
 func (m ReviewModel) Insert(review *Review) error {
+	if review.Status == "" {
+		review.Status = ReviewPending
+	}
+
 	query := `
-        INSERT INTO reviews (product_id, content, author, rating)
-        VALUES ($1, $2, $3, $4)
+        INSERT INTO reviews (product_id, user_id, variant_id, content, author, rating, status, screening_score, screening_flags)
+        VALUES ($1, NULLIF($2, 0), NULLIF($3, 0), $4, $5, $6, $7, $8, $9)
         RETURNING id, created_at, updated_at`
 
-	args := []interface{}{review.ProductID, review.Content, review.Author, review.Rating}
+	args := []interface{}{review.ProductID, review.UserID, review.VariantID, review.Content, review.Author, review.Rating, review.Status,
+		review.ScreeningScore, pq.Array(review.ScreeningFlags)}
 
-	return m.DB.QueryRow(query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt)
+	err := m.DB.QueryRow(query, args...).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt)
+	return duplicateReviewError(err)
+}
+
+// duplicateReviewError turns a violation of either per-author unique index into ErrDuplicateReview
+func duplicateReviewError(err error) error {
+	var pqError *pq.Error
+	if errors.As(err, &pqError) && pqError.Code == "23505" &&
+		(pqError.Constraint == "reviews_product_user_key" || pqError.Constraint == "reviews_product_author_key") {
+		return ErrDuplicateReview
+	}
+	return err
 }
 
 // Get retrieves a specific review by ID.
 func (m ReviewModel) Get(id int64) (*Review, error) {
 	query := `
-        SELECT id, product_id, content, author, rating, helpful_count, created_at, updated_at
+        SELECT id, product_id, COALESCE(user_id, 0), COALESCE(variant_id, 0), content, author, rating, helpful_count, created_at, updated_at, status,
+               COALESCE(moderation_reason, ''), moderated_at, screening_score, screening_flags, edited
         FROM reviews
-        WHERE id = $1`
+        WHERE id = $1 AND deleted_at IS NULL`
 
 	var review Review
 	err := m.DB.QueryRow(query, id).Scan(
 		&review.ID,
 		&review.ProductID,
+		&review.UserID,
+		&review.VariantID,
 		&review.Content,
 		&review.Author,
 		&review.Rating,
 		&review.HelpfulCount,
 		&review.CreatedAt,
 		&review.UpdatedAt,
+		&review.Status,
+		&review.ModerationReason,
+		&review.ModeratedAt,
+		&review.ScreeningScore,
+		pq.Array(&review.ScreeningFlags),
+		&review.Edited,
 	)
 
 	if err == sql.ErrNoRows {
@@ -67,42 +131,164 @@ func (m ReviewModel) Get(id int64) (*Review, error) {
 		return nil, err
 	}
 
+	err = m.attachResponses(&review)
+	if err != nil {
+		return nil, err
+	}
+	err = m.attachMedia(&review)
+	if err != nil {
+		return nil, err
+	}
+
 	return &review, nil
 }
 
-// Update modifies an existing review in the database.
+// GetForUser retrieves the user's review of a product, ignoring rejected
+// reviews, which don't stop the user writing another.
+func (m ReviewModel) GetForUser(productID int64, userID int64) (*Review, error) {
+	query := `
+        SELECT id
+        FROM reviews
+        WHERE product_id = $1 AND user_id = $2 AND status <> 'rejected' AND deleted_at IS NULL`
+
+	var id int64
+	err := m.DB.QueryRow(query, productID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("review not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return m.Get(id)
+}
+
+// Update modifies an existing review in the database and marks it as edited.
 func (m ReviewModel) Update(review *Review) error {
 	query := `
         UPDATE reviews
-        SET content = $1, author = $2, rating = $3, updated_at = NOW()
-        WHERE id = $4`
+        SET content = $1, author = $2, rating = $3, status = $4, screening_score = $5, screening_flags = $6,
+            variant_id = NULLIF($7, 0), edited = TRUE, updated_at = NOW()
+        WHERE id = $8 AND deleted_at IS NULL`
 
-	args := []interface{}{review.Content, review.Author, review.Rating, review.ID}
+	args := []interface{}{review.Content, review.Author, review.Rating, review.Status,
+		review.ScreeningScore, pq.Array(review.ScreeningFlags), review.VariantID, review.ID}
 	_, err := m.DB.Exec(query, args...)
-	return err
+	if err != nil {
+		return duplicateReviewError(err)
+	}
+	review.Edited = true
+	return nil
+}
+
+// RecentByAuthor returns the content of the author's most recent reviews
+// from the last 30 days, newest first, leaving out the review excludeID.
+func (m ReviewModel) RecentByAuthor(author string, excludeID int64, limit int) ([]string, error) {
+	query := `
+        SELECT content
+        FROM reviews
+        WHERE author = $1 AND id <> $2 AND created_at > NOW() - INTERVAL '30 days' AND deleted_at IS NULL
+        ORDER BY created_at DESC
+        LIMIT $3`
+
+	rows, err := m.DB.Query(query, author, excludeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contents []string
+	for rows.Next() {
+		var content string
+		err := rows.Scan(&content)
+		if err != nil {
+			return nil, err
+		}
+		contents = append(contents, content)
+	}
+
+	return contents, rows.Err()
 }
 
-// Delete removes a review by ID from the database.
+// MarkHelpful records userID's helpful vote on a review and refreshes
+// review.HelpfulCount. Each user counts once; it reports false, leaving the
+// count alone, when userID has already voted.
+func (m ReviewModel) MarkHelpful(review *Review, userID int64) (bool, error) {
+	query := `
+        WITH vote AS (
+            INSERT INTO review_helpful_votes (review_id, user_id)
+            VALUES ($1, $2)
+            ON CONFLICT DO NOTHING
+            RETURNING review_id
+        )
+        UPDATE reviews
+        SET helpful_count = helpful_count + 1
+        WHERE id IN (SELECT review_id FROM vote)
+        RETURNING helpful_count`
+
+	err := m.DB.QueryRow(query, review.ID, userID).Scan(&review.HelpfulCount)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete soft-deletes a review. Restore brings it back until PurgeDeleted
+// removes it for good.
 func (m ReviewModel) Delete(id int64) error {
 	query := `
-        DELETE FROM reviews
-        WHERE id = $1`
+        UPDATE reviews
+        SET deleted_at = NOW()
+        WHERE id = $1 AND deleted_at IS NULL`
 
 	_, err := m.DB.Exec(query, id)
 	return err
 }
 
+// Restore undoes Delete. A review can't be restored while its product is
+// deleted; restore the product instead.
+func (m ReviewModel) Restore(id int64) (*Review, error) {
+	query := `
+        UPDATE reviews
+        SET deleted_at = NULL
+        WHERE id = $1 AND deleted_at IS NOT NULL
+          AND product_id IN (SELECT id FROM products WHERE deleted_at IS NULL)
+        RETURNING id`
+
+	err := m.DB.QueryRow(query, id).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("review not found")
+	} else if err != nil {
+		return nil, duplicateReviewError(err)
+	}
+
+	return m.Get(id)
+}
+
+// PurgeDeleted permanently removes reviews deleted before cutoff.
+func (m ReviewModel) PurgeDeleted(cutoff time.Time) error {
+	query := `
+        DELETE FROM reviews
+        WHERE deleted_at < $1`
+
+	_, err := m.DB.Exec(query, cutoff)
+	return err
+}
+
 // GetAll retrieves all reviews with optional filtering, sorting, and pagination.
-func (m ReviewModel) GetAll(productID int64, sort string, limit int, offset int) ([]*Review, error) {
+// A variantID of zero matches reviews of every variant.
+func (m ReviewModel) GetAll(productID int64, variantID int64, sort string, limit int, offset int) ([]*Review, error) {
 	query := `
-        SELECT id, product_id, content, author, rating, helpful_count, created_at, updated_at
+        SELECT id, product_id, COALESCE(user_id, 0), COALESCE(variant_id, 0), content, author, rating, helpful_count, created_at, updated_at, status,
+               COALESCE(moderation_reason, ''), moderated_at, screening_score, screening_flags, edited
         FROM reviews
         WHERE (product_id = $1 OR $1 = 0)
-        ORDER BY CASE WHEN $2 = 'helpful' THEN helpful_count END DESC,
-                 CASE WHEN $2 = 'date' THEN created_at END DESC
-        LIMIT $3 OFFSET $4`
+          AND (variant_id = $2 OR $2 = 0)
+          AND status = 'approved' AND deleted_at IS NULL
+        ORDER BY CASE WHEN $3 = 'helpful' THEN helpful_count END DESC,
+                 CASE WHEN $3 = 'date' THEN created_at END DESC
+        LIMIT $4 OFFSET $5`
 
-	args := []interface{}{productID, sort, limit, offset}
+	args := []interface{}{productID, variantID, sort, limit, offset}
 
 	rows, err := m.DB.Query(query, args...)
 	if err != nil {
@@ -116,18 +302,193 @@ func (m ReviewModel) GetAll(productID int64, sort string, limit int, offset int)
 		err := rows.Scan(
 			&review.ID,
 			&review.ProductID,
+			&review.UserID,
+			&review.VariantID,
 			&review.Content,
 			&review.Author,
 			&review.Rating,
 			&review.HelpfulCount,
 			&review.CreatedAt,
 			&review.UpdatedAt,
+			&review.Status,
+			&review.ModerationReason,
+			&review.ModeratedAt,
+			&review.ScreeningScore,
+			pq.Array(&review.ScreeningFlags),
+			&review.Edited,
 		)
 		if err != nil {
 			return nil, err
 		}
 		reviews = append(reviews, &review)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.attachResponses(reviews...)
+	if err != nil {
+		return nil, err
+	}
+	err = m.attachMedia(reviews...)
+	if err != nil {
+		return nil, err
+	}
 
 	return reviews, nil
 }
+
+// Export streams every review matching the filters to fn without a limit or offset.
+func (m ReviewModel) Export(ctx context.Context, productID int64, sort string, fn func(*Review) error) error {
+	query := `
+        SELECT id, product_id, COALESCE(user_id, 0), COALESCE(variant_id, 0), content, author, rating, helpful_count, created_at, updated_at, status,
+               COALESCE(moderation_reason, ''), moderated_at, screening_score, screening_flags, edited
+        FROM reviews
+        WHERE (product_id = $1 OR $1 = 0)
+          AND status = 'approved' AND deleted_at IS NULL
+        ORDER BY CASE WHEN $2 = 'helpful' THEN helpful_count END DESC,
+                 CASE WHEN $2 = 'date' THEN created_at END DESC,
+                 id`
+
+	args := []interface{}{productID, sort}
+
+	return streamCursor(ctx, m.DB, query, args, func(rows *sql.Rows) error {
+		var review Review
+		err := rows.Scan(
+			&review.ID,
+			&review.ProductID,
+			&review.UserID,
+			&review.VariantID,
+			&review.Content,
+			&review.Author,
+			&review.Rating,
+			&review.HelpfulCount,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Status,
+			&review.ModerationReason,
+			&review.ModeratedAt,
+			&review.ScreeningScore,
+			pq.Array(&review.ScreeningFlags),
+			&review.Edited,
+		)
+		if err != nil {
+			return err
+		}
+		return fn(&review)
+	})
+}
+
+// RatingCount is the number of reviews a product has at one rating.
+type RatingCount struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
+}
+
+// TopForProducts returns up to limit reviews for each of the given products
+// in a single query, ordered the same way as GetAll. Products without
+// reviews are absent from the map.
+func (m ReviewModel) TopForProducts(productIDs []int64, sort string, limit int) (map[int64][]*Review, error) {
+	query := `
+        SELECT id, product_id, COALESCE(user_id, 0), COALESCE(variant_id, 0), content, author, rating, helpful_count, created_at, updated_at, status,
+               COALESCE(moderation_reason, ''), moderated_at, screening_score, screening_flags, edited
+        FROM (
+            SELECT *, ROW_NUMBER() OVER (
+                PARTITION BY product_id
+                ORDER BY CASE WHEN $2 = 'date' THEN created_at END DESC,
+                         helpful_count DESC, created_at DESC
+            ) AS position
+            FROM reviews
+            WHERE product_id = ANY($1) AND status = 'approved' AND deleted_at IS NULL
+        ) ranked
+        WHERE position <= $3
+        ORDER BY product_id, position`
+
+	rows, err := m.DB.Query(query, pq.Array(productIDs), sort, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := make(map[int64][]*Review)
+	var all []*Review
+	for rows.Next() {
+		var review Review
+		err := rows.Scan(
+			&review.ID,
+			&review.ProductID,
+			&review.UserID,
+			&review.VariantID,
+			&review.Content,
+			&review.Author,
+			&review.Rating,
+			&review.HelpfulCount,
+			&review.CreatedAt,
+			&review.UpdatedAt,
+			&review.Status,
+			&review.ModerationReason,
+			&review.ModeratedAt,
+			&review.ScreeningScore,
+			pq.Array(&review.ScreeningFlags),
+			&review.Edited,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reviews[review.ProductID] = append(reviews[review.ProductID], &review)
+		all = append(all, &review)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = m.attachResponses(all...)
+	if err != nil {
+		return nil, err
+	}
+	err = m.attachMedia(all...)
+	if err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// RatingHistograms counts reviews per rating for each of the given products.
+// Every product gets all five ratings, with zero counts where there are none.
+func (m ReviewModel) RatingHistograms(productIDs []int64) (map[int64][]RatingCount, error) {
+	query := `
+        SELECT product_id, rating, COUNT(*)
+        FROM reviews
+        WHERE product_id = ANY($1) AND status = 'approved' AND deleted_at IS NULL
+        GROUP BY product_id, rating`
+
+	rows, err := m.DB.Query(query, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histograms := make(map[int64][]RatingCount)
+	for _, id := range productIDs {
+		histogram := make([]RatingCount, 5)
+		for i := range histogram {
+			histogram[i].Rating = i + 1
+		}
+		histograms[id] = histogram
+	}
+
+	for rows.Next() {
+		var productID int64
+		var rating, count int
+		err := rows.Scan(&productID, &rating, &count)
+		if err != nil {
+			return nil, err
+		}
+		if rating >= 1 && rating <= 5 {
+			histograms[productID][rating-1].Count = count
+		}
+	}
+
+	return histograms, rows.Err()
+}
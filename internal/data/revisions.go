@@ -0,0 +1,136 @@
+// internal/data/revisions.go
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+// Kinds of record whose edits are kept as revisions
+const (
+	RevisionProduct = "product"
+	RevisionReview  = "review"
+)
+
+// FieldChange is one field's value before and after an edit.
+type FieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Revision records one edit of a product or review: who made it, when,
+// the record as it was before, and the fields that changed.
+type Revision struct {
+	ID        int64                  `json:"id"`
+	Kind      string                 `json:"kind"`
+	RecordID  int64                  `json:"record_id"`
+	UserID    int64                  `json:"user_id,omitempty"`
+	Previous  json.RawMessage        `json:"previous"`
+	Changes   map[string]FieldChange `json:"changes"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type RevisionModel struct {
+	DB DBTX
+}
+
+// Record stores the prior version of a record and how current differs
+// from it. previous and current are compared through their JSON form,
+// so only fields a client can see are diffed. An edit that changes
+// nothing isn't recorded. A userID of zero records an anonymous edit.
+func (m RevisionModel) Record(kind string, recordID int64, userID int64, previous any, current any) error {
+	before, err := json.Marshal(previous)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	changes, err := diffJSON(before, after)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	diff, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO revisions (kind, record_id, user_id, previous, changes)
+        VALUES ($1, $2, NULLIF($3, 0), $4, $5)`
+
+	_, err = m.DB.Exec(query, kind, recordID, userID, before, diff)
+	return err
+}
+
+// diffJSON compares the top-level members of two JSON objects
+func diffJSON(before []byte, after []byte) (map[string]FieldChange, error) {
+	var from, to map[string]any
+	err := json.Unmarshal(before, &from)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(after, &to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]FieldChange)
+	for name, value := range to {
+		if !reflect.DeepEqual(from[name], value) {
+			changes[name] = FieldChange{From: from[name], To: value}
+		}
+	}
+	for name, value := range from {
+		if _, ok := to[name]; !ok {
+			changes[name] = FieldChange{From: value}
+		}
+	}
+	return changes, nil
+}
+
+// ForRecord returns the revisions of one record, newest first.
+func (m RevisionModel) ForRecord(kind string, recordID int64, filters Filters) ([]*Revision, error) {
+	filters.ValidateFilter()
+
+	query := `
+        SELECT id, kind, record_id, COALESCE(user_id, 0), previous, changes, created_at
+        FROM revisions
+        WHERE kind = $1 AND record_id = $2
+        ORDER BY id DESC
+        LIMIT $3 OFFSET $4`
+
+	rows, err := m.DB.Query(query, kind, recordID, filters.Limit, filters.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+	for rows.Next() {
+		var revision Revision
+		var changes []byte
+		err := rows.Scan(
+			&revision.ID,
+			&revision.Kind,
+			&revision.RecordID,
+			&revision.UserID,
+			&revision.Previous,
+			&changes,
+			&revision.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(changes, &revision.Changes)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, &revision)
+	}
+
+	return revisions, rows.Err()
+}
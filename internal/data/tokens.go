@@ -0,0 +1,80 @@
+// internal/data/tokens.go
+package data
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// ScopeAuthentication is the scope of bearer tokens used to authenticate requests.
+const ScopeAuthentication = "authentication"
+
+// Token is an opaque bearer token. Only its SHA-256 hash is stored; the
+// plaintext is returned to the client once, when the token is created.
+type Token struct {
+	Plaintext string    `json:"token"`
+	Hash      []byte    `json:"-"`
+	UserID    int64     `json:"-"`
+	Expiry    time.Time `json:"expiry"`
+	Scope     string    `json:"-"`
+}
+
+func generateToken(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{
+		Plaintext: base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes),
+		UserID:    userID,
+		Expiry:    time.Now().Add(ttl),
+		Scope:     scope,
+	}
+	hash := sha256.Sum256([]byte(token.Plaintext))
+	token.Hash = hash[:]
+	return token, nil
+}
+
+// ValidateTokenPlaintext checks the shape of a token sent by a client.
+func ValidateTokenPlaintext(v *validator.Validator, plaintext string) {
+	v.Check(plaintext != "", "token", "must be provided")
+	v.Check(len(plaintext) == 26, "token", "must be 26 bytes long")
+}
+
+type TokenModel struct {
+	DB DBTX
+}
+
+// New creates and stores a token for a user.
+func (m TokenModel) New(userID int64, ttl time.Duration, scope string) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+        INSERT INTO tokens (hash, user_id, expiry, scope)
+        VALUES ($1, $2, $3, $4)`
+
+	_, err = m.DB.Exec(query, token.Hash, token.UserID, token.Expiry, token.Scope)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// DeleteExpired removes tokens past their expiry.
+func (m TokenModel) DeleteExpired() error {
+	query := `
+        DELETE FROM tokens
+        WHERE expiry < NOW()`
+
+	_, err := m.DB.Exec(query)
+	return err
+}
@@ -0,0 +1,211 @@
+// internal/data/users.go
+package data
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrDuplicateEmail is returned by Insert when the email is already registered.
+var ErrDuplicateEmail = errors.New("duplicate email")
+
+// EmailRX is a loose check that an address has a local part and a domain.
+var EmailRX = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// AnonymousUser represents a request without an authentication token.
+var AnonymousUser = &User{}
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Password  password  `json:"-"`
+	Version   int       `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IsAnonymous reports whether u is the AnonymousUser.
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
+// password holds a plaintext password only between reading the request
+// and hashing it; the hash is what gets stored.
+type password struct {
+	plaintext *string
+	hash      []byte
+}
+
+// Set hashes plaintext with bcrypt.
+func (p *password) Set(plaintext string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), 12)
+	if err != nil {
+		return err
+	}
+	p.plaintext = &plaintext
+	p.hash = hash
+	return nil
+}
+
+// Matches reports whether plaintext is the password.
+func (p *password) Matches(plaintext string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword(p.hash, []byte(plaintext))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type UserModel struct {
+	DB DBTX
+}
+
+func ValidateEmail(v *validator.Validator, email string) {
+	v.Check(email != "", "email", "must be provided")
+	v.Check(EmailRX.MatchString(email), "email", "must be a valid email address")
+}
+
+func ValidatePasswordPlaintext(v *validator.Validator, password string) {
+	v.Check(password != "", "password", "must be provided")
+	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
+	v.Check(len(password) <= 72, "password", "must not be more than 72 bytes long")
+}
+
+func ValidateUser(v *validator.Validator, user *User) {
+	v.Check(user.Name != "", "name", "must be provided")
+	v.Check(len(user.Name) <= 100, "name", "must not be more than 100 characters")
+	ValidateEmail(v, user.Email)
+	if user.Password.plaintext != nil {
+		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+	}
+	if user.Password.hash == nil {
+		panic("missing password hash for user")
+	}
+}
+
+// Insert adds a new user. Emails are stored lower-cased so lookups are case-insensitive.
+func (m UserModel) Insert(user *User) error {
+	query := `
+        INSERT INTO users (name, email, password_hash)
+        VALUES ($1, LOWER($2), $3)
+        RETURNING id, email, version, created_at`
+
+	args := []interface{}{user.Name, user.Email, user.Password.hash}
+
+	err := m.DB.QueryRow(query, args...).Scan(&user.ID, &user.Email, &user.Version, &user.CreatedAt)
+	var pqError *pq.Error
+	if errors.As(err, &pqError) && pqError.Code == "23505" {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+// GetByEmail retrieves a user by email address.
+func (m UserModel) GetByEmail(email string) (*User, error) {
+	query := `
+        SELECT id, name, email, password_hash, version, created_at
+        FROM users
+        WHERE email = LOWER($1)`
+
+	var user User
+	err := m.DB.QueryRow(query, email).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Version,
+		&user.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetForToken retrieves the user holding an unexpired token with the given scope.
+func (m UserModel) GetForToken(scope string, plaintext string) (*User, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+        SELECT u.id, u.name, u.email, u.password_hash, u.version, u.created_at
+        FROM users u
+        JOIN tokens t ON t.user_id = u.id
+        WHERE t.hash = $1 AND t.scope = $2 AND t.expiry > NOW()`
+
+	var user User
+	err := m.DB.QueryRow(query, hash[:], scope).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Version,
+		&user.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Permissions is a list of permission codes such as "reviews:moderate".
+type Permissions []string
+
+// Include reports whether code is in the list.
+func (p Permissions) Include(code string) bool {
+	for _, c := range p {
+		if strings.EqualFold(c, code) {
+			return true
+		}
+	}
+	return false
+}
+
+type PermissionModel struct {
+	DB DBTX
+}
+
+// GetAllForUser returns the permission codes granted to a user.
+func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	query := `
+        SELECT p.code
+        FROM permissions p
+        JOIN users_permissions up ON up.permission_id = p.id
+        WHERE up.user_id = $1`
+
+	rows, err := m.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var code string
+		err := rows.Scan(&code)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, code)
+	}
+
+	return permissions, rows.Err()
+}
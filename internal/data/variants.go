@@ -0,0 +1,182 @@
+// internal/data/variants.go
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
+)
+
+// ErrDuplicateSKU is returned by Insert and Update when another variant
+// already has the SKU.
+var ErrDuplicateSKU = errors.New("duplicate sku")
+
+// Variant is a purchasable version of a product, such as a size or
+// colour. Price is in minor units and is left out when the variant sells
+// at the product's price. AverageRating and ReviewCount cover the approved
+// reviews written about the variant; the product's own average rating
+// covers every variant.
+type Variant struct {
+	ID            int64             `json:"id"`
+	ProductID     int64             `json:"product_id"`
+	SKU           string            `json:"sku"`
+	Attributes    map[string]string `json:"attributes"`
+	Price         *int64            `json:"price,omitempty"`
+	AverageRating float32           `json:"average_rating"`
+	ReviewCount   int               `json:"review_count"`
+	CreatedAt     time.Time         `json:"-"`
+	UpdatedAt     time.Time         `json:"-"`
+}
+
+type VariantModel struct {
+	DB DBTX
+}
+
+func ValidateVariant(v *validator.Validator, variant *Variant) {
+	v.Check(variant.SKU != "", "sku", "must be provided")
+	v.Check(len(variant.SKU) <= 64, "sku", "must not be more than 64 characters")
+	v.Check(len(variant.Attributes) <= 10, "attributes", "must not have more than 10 entries")
+	for name, value := range variant.Attributes {
+		v.Check(name != "" && len(name) <= 50, "attributes", "names must be between 1 and 50 characters")
+		v.Check(value != "" && len(value) <= 100, "attributes", "values must be between 1 and 100 characters")
+	}
+	v.Check(variant.Price == nil || *variant.Price >= 0, "price", "must not be negative")
+}
+
+// Insert adds a variant to a product.
+func (m VariantModel) Insert(variant *Variant) error {
+	attributes, err := variantAttributes(variant)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO product_variants (product_id, sku, attributes, price)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, updated_at`
+
+	args := []interface{}{variant.ProductID, variant.SKU, attributes, variant.Price}
+	err = m.DB.QueryRow(query, args...).Scan(&variant.ID, &variant.CreatedAt, &variant.UpdatedAt)
+	return duplicateSKUError(err)
+}
+
+// Get retrieves a variant by ID together with its rating.
+func (m VariantModel) Get(id int64) (*Variant, error) {
+	query := `
+        SELECT v.id, v.product_id, v.sku, v.attributes, v.price, v.created_at, v.updated_at,
+               COALESCE(AVG(r.rating), 0), COUNT(r.id)
+        FROM product_variants v
+        LEFT JOIN reviews r ON r.variant_id = v.id AND r.status = 'approved' AND r.deleted_at IS NULL
+        WHERE v.id = $1
+        GROUP BY v.id`
+
+	variant, err := scanVariant(m.DB.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("variant not found")
+	}
+	return variant, err
+}
+
+// ForProduct returns a product's variants with their ratings, oldest first.
+func (m VariantModel) ForProduct(productID int64) ([]*Variant, error) {
+	query := `
+        SELECT v.id, v.product_id, v.sku, v.attributes, v.price, v.created_at, v.updated_at,
+               COALESCE(AVG(r.rating), 0), COUNT(r.id)
+        FROM product_variants v
+        LEFT JOIN reviews r ON r.variant_id = v.id AND r.status = 'approved' AND r.deleted_at IS NULL
+        WHERE v.product_id = $1
+        GROUP BY v.id
+        ORDER BY v.id`
+
+	rows, err := m.DB.Query(query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []*Variant{}
+	for rows.Next() {
+		variant, err := scanVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// Update modifies a variant's SKU, attributes and price.
+func (m VariantModel) Update(variant *Variant) error {
+	attributes, err := variantAttributes(variant)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE product_variants
+        SET sku = $1, attributes = $2, price = $3, updated_at = NOW()
+        WHERE id = $4
+        RETURNING updated_at`
+
+	args := []interface{}{variant.SKU, attributes, variant.Price, variant.ID}
+	err = m.DB.QueryRow(query, args...).Scan(&variant.UpdatedAt)
+	return duplicateSKUError(err)
+}
+
+// Delete removes a variant. Its reviews stay with the product but no
+// longer name a variant.
+func (m VariantModel) Delete(id int64) error {
+	query := `
+        DELETE FROM product_variants
+        WHERE id = $1`
+
+	_, err := m.DB.Exec(query, id)
+	return err
+}
+
+// variantAttributes encodes a variant's attributes for the JSONB column
+func variantAttributes(variant *Variant) ([]byte, error) {
+	if variant.Attributes == nil {
+		variant.Attributes = map[string]string{}
+	}
+	return json.Marshal(variant.Attributes)
+}
+
+// duplicateSKUError turns a violation of the unique SKU index into ErrDuplicateSKU
+func duplicateSKUError(err error) error {
+	var pqError *pq.Error
+	if errors.As(err, &pqError) && pqError.Code == "23505" {
+		return ErrDuplicateSKU
+	}
+	return err
+}
+
+func scanVariant(row interface{ Scan(...any) error }) (*Variant, error) {
+	var variant Variant
+	var attributes []byte
+	var price sql.NullInt64
+	err := row.Scan(
+		&variant.ID,
+		&variant.ProductID,
+		&variant.SKU,
+		&attributes,
+		&price,
+		&variant.CreatedAt,
+		&variant.UpdatedAt,
+		&variant.AverageRating,
+		&variant.ReviewCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if price.Valid {
+		variant.Price = &price.Int64
+	}
+	return &variant, json.Unmarshal(attributes, &variant.Attributes)
+}
@@ -0,0 +1,366 @@
+// internal/data/webhook.go
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+	"github.com/lib/pq"
+)
+
+// Webhook delivery statuses. A dead delivery has used up its attempts and
+// stays in the dead-letter view until it is retried by hand.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Webhook is a subscription that receives outbox events by HTTP POST. The
+// secret used to sign payloads is only returned when the webhook is created.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description,omitempty"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	Secret      string    `json:"secret,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"-"`
+}
+
+// WebhookDelivery is one attempt to send one event to one webhook.
+type WebhookDelivery struct {
+	ID            int64      `json:"id"`
+	WebhookID     int64      `json:"webhook_id"`
+	EventID       int64      `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastStatus    int        `json:"last_status,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// PendingDelivery is a claimed delivery together with what is needed to send it.
+type PendingDelivery struct {
+	WebhookDelivery
+	URL            string
+	Secret         string
+	Payload        json.RawMessage
+	EventCreatedAt time.Time
+}
+
+type WebhookModel struct {
+	DB DBTX
+}
+
+// DeliveryStatuses lists the accepted values for the deliveries status filter.
+var DeliveryStatuses = []string{DeliveryPending, DeliveryDelivered, DeliveryDead}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	u, err := url.Parse(webhook.URL)
+	v.Check(webhook.URL != "", "url", "must be provided")
+	v.Check(len(webhook.URL) <= 2048, "url", "must not be more than 2048 characters")
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an absolute http or https URL")
+	if err == nil {
+		v.Check(publicHost(u.Hostname()), "url", "must not point at a loopback, link-local or private address")
+	}
+	v.Check(len(webhook.Description) <= 255, "description", "must not be more than 255 characters")
+
+	v.Check(len(webhook.Events) > 0, "events", "must contain at least one event type")
+	known := map[string]bool{"*": true}
+	for _, eventType := range OutboxEventTypes {
+		known[eventType] = true
+	}
+	for _, eventType := range webhook.Events {
+		v.Check(known[eventType], "events", fmt.Sprintf("unknown event type: %s", eventType))
+	}
+}
+
+// publicHost reports whether host may be a webhook's destination. Names
+// are only checked for localhost here; the dispatcher checks the address
+// they resolve to when it dials.
+func publicHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return true
+	}
+	return PublicAddr(addr)
+}
+
+// cgnat is the shared address space carriers use behind NAT (RFC 6598).
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicAddr reports whether addr is a globally routable unicast address,
+// rather than a loopback, link-local, private or otherwise internal one.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !cgnat.Contains(addr)
+}
+
+// Insert adds a new webhook to the database.
+func (m WebhookModel) Insert(webhook *Webhook) error {
+	query := `
+        INSERT INTO webhooks (url, description, events, secret, active)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, created_at, updated_at`
+
+	args := []interface{}{webhook.URL, webhook.Description, pq.Array(webhook.Events), webhook.Secret, webhook.Active}
+
+	return m.DB.QueryRow(query, args...).Scan(&webhook.ID, &webhook.CreatedAt, &webhook.UpdatedAt)
+}
+
+// Get retrieves a specific webhook by ID, without its secret.
+func (m WebhookModel) Get(id int64) (*Webhook, error) {
+	query := `
+        SELECT id, url, description, events, active, created_at, updated_at
+        FROM webhooks
+        WHERE id = $1`
+
+	var webhook Webhook
+	err := m.DB.QueryRow(query, id).Scan(
+		&webhook.ID,
+		&webhook.URL,
+		&webhook.Description,
+		pq.Array(&webhook.Events),
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("webhook not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+// GetAll retrieves webhooks, oldest first, without their secrets.
+func (m WebhookModel) GetAll(filters Filters) ([]*Webhook, error) {
+	filters.ValidateFilter()
+
+	query := `
+        SELECT id, url, description, events, active, created_at, updated_at
+        FROM webhooks
+        ORDER BY id
+        LIMIT $1 OFFSET $2`
+
+	rows, err := m.DB.Query(query, filters.Limit, filters.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		var webhook Webhook
+		err := rows.Scan(
+			&webhook.ID,
+			&webhook.URL,
+			&webhook.Description,
+			pq.Array(&webhook.Events),
+			&webhook.Active,
+			&webhook.CreatedAt,
+			&webhook.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Update modifies an existing webhook. The secret is left unchanged.
+func (m WebhookModel) Update(webhook *Webhook) error {
+	query := `
+        UPDATE webhooks
+        SET url = $1, description = $2, events = $3, active = $4, updated_at = NOW()
+        WHERE id = $5
+        RETURNING updated_at`
+
+	args := []interface{}{webhook.URL, webhook.Description, pq.Array(webhook.Events), webhook.Active, webhook.ID}
+	return m.DB.QueryRow(query, args...).Scan(&webhook.UpdatedAt)
+}
+
+// Delete removes a webhook and its deliveries.
+func (m WebhookModel) Delete(id int64) error {
+	query := `
+        DELETE FROM webhooks
+        WHERE id = $1`
+
+	_, err := m.DB.Exec(query, id)
+	return err
+}
+
+// Deliveries lists a webhook's deliveries, newest first. An empty status
+// matches every status; "dead" gives the dead-letter view.
+func (m WebhookModel) Deliveries(webhookID int64, status string, filters Filters) ([]*WebhookDelivery, error) {
+	filters.ValidateFilter()
+
+	query := `
+        SELECT d.id, d.webhook_id, d.outbox_id, o.type, d.status, d.attempts, d.next_attempt_at,
+               COALESCE(d.last_status, 0), COALESCE(d.last_error, ''), d.created_at, d.delivered_at
+        FROM webhook_deliveries d
+        JOIN outbox o ON o.id = d.outbox_id
+        WHERE d.webhook_id = $1 AND (d.status = $2 OR $2 = '')
+        ORDER BY d.id DESC
+        LIMIT $3 OFFSET $4`
+
+	rows, err := m.DB.Query(query, webhookID, status, filters.Limit, filters.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*WebhookDelivery
+	for rows.Next() {
+		var delivery WebhookDelivery
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastStatus,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.DeliveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// ClaimDue takes up to limit pending deliveries that are due and pushes
+// their next attempt lease into the future, so a dispatcher that dies
+// mid-delivery leaves them to be picked up again once the lease expires.
+func (m WebhookModel) ClaimDue(limit int, lease time.Duration) ([]*PendingDelivery, error) {
+	query := `
+        UPDATE webhook_deliveries d
+        SET next_attempt_at = NOW() + make_interval(secs => $2)
+        FROM webhooks w, outbox o
+        WHERE d.id IN (
+                SELECT id FROM webhook_deliveries
+                WHERE status = 'pending' AND next_attempt_at <= NOW()
+                ORDER BY next_attempt_at
+                LIMIT $1
+                FOR UPDATE SKIP LOCKED
+            )
+          AND w.id = d.webhook_id
+          AND o.id = d.outbox_id
+        RETURNING d.id, d.webhook_id, d.outbox_id, o.type, d.status, d.attempts, d.created_at,
+                  w.url, w.secret, o.payload, o.created_at`
+
+	rows, err := m.DB.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*PendingDelivery
+	for rows.Next() {
+		var delivery PendingDelivery
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.CreatedAt,
+			&delivery.URL,
+			&delivery.Secret,
+			&delivery.Payload,
+			&delivery.EventCreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// MarkDelivered records a successful attempt.
+func (m WebhookModel) MarkDelivered(id int64, statusCode int) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = 'delivered', attempts = attempts + 1, last_status = $2,
+            last_error = NULL, delivered_at = NOW()
+        WHERE id = $1`
+
+	_, err := m.DB.Exec(query, id, statusCode)
+	return err
+}
+
+// MarkFailed records a failed attempt and schedules the next one at
+// retryAt. A nil retryAt moves the delivery to the dead-letter view.
+func (m WebhookModel) MarkFailed(id int64, statusCode int, message string, retryAt *time.Time) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = CASE WHEN $4::timestamptz IS NULL THEN 'dead' ELSE 'pending' END,
+            attempts = attempts + 1, last_status = NULLIF($2, 0), last_error = $3,
+            next_attempt_at = COALESCE($4, next_attempt_at)
+        WHERE id = $1`
+
+	_, err := m.DB.Exec(query, id, statusCode, message, retryAt)
+	return err
+}
+
+// Retry puts a webhook's delivery back in the queue with a fresh set of attempts.
+func (m WebhookModel) Retry(webhookID int64, id int64) (*WebhookDelivery, error) {
+	query := `
+        UPDATE webhook_deliveries d
+        SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+        FROM outbox o
+        WHERE d.id = $1 AND d.webhook_id = $2 AND o.id = d.outbox_id
+        RETURNING d.id, d.webhook_id, d.outbox_id, o.type, d.status, d.attempts, d.next_attempt_at,
+                  COALESCE(d.last_status, 0), COALESCE(d.last_error, ''), d.created_at, d.delivered_at`
+
+	var delivery WebhookDelivery
+	err := m.DB.QueryRow(query, id, webhookID).Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatus,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.DeliveredAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("delivery not found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
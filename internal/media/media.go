@@ -0,0 +1,143 @@
+// Package media checks and prepares uploaded images. Every upload is
+// decoded and encoded again, which drops EXIF and any other metadata the
+// file carried, and resized copies are made for thumbnails.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+// ErrUnsupportedType is returned for files that aren't JPEG or PNG images,
+// whatever their name or declared content type says.
+var ErrUnsupportedType = errors.New("file must be a JPEG or PNG image")
+
+// TooLargeError is returned when a file is bigger than the limits allow.
+type TooLargeError struct {
+	Message string
+}
+
+func (e *TooLargeError) Error() string {
+	return e.Message
+}
+
+// Options limit what Process accepts and list the variants it makes.
+type Options struct {
+	MaxBytes  int64 // largest file accepted
+	MaxPixels int   // largest width times height accepted, which bounds decoding memory
+	Sizes     []int // longest side of each resized variant, in pixels
+}
+
+// Variant is a resized copy of an image.
+type Variant struct {
+	Size   int
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// Image is an upload that passed the checks, re-encoded without metadata.
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+	Variants    []Variant
+}
+
+// formats maps the sniffed content types accepted to their file extension
+var formats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// Process reads an upload, checks it is an image within the limits, turns
+// it upright according to its EXIF orientation and re-encodes it along
+// with its variants.
+func Process(r io.Reader, opts Options) (*Image, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, opts.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > opts.MaxBytes {
+		return nil, &TooLargeError{Message: fmt.Sprintf("file must not be larger than %d bytes", opts.MaxBytes)}
+	}
+
+	// the content is trusted over whatever the client said it was
+	contentType := http.DetectContentType(raw)
+	ext, ok := formats[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if config.Width*config.Height > opts.MaxPixels {
+		return nil, &TooLargeError{Message: fmt.Sprintf("image must not have more than %d pixels", opts.MaxPixels)}
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	img := toRGBA(decoded)
+	if contentType == "image/jpeg" {
+		img = orient(img, jpegOrientation(raw))
+	}
+
+	data, err := encode(img, contentType)
+	if err != nil {
+		return nil, err
+	}
+	result := &Image{
+		ContentType: contentType,
+		Ext:         ext,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Data:        data,
+	}
+
+	for _, size := range opts.Sizes {
+		resized := Fit(img, size)
+		data, err := encode(resized, contentType)
+		if err != nil {
+			return nil, err
+		}
+		result.Variants = append(result.Variants, Variant{
+			Size:   size,
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+			Data:   data,
+		})
+	}
+
+	return result, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	}
+	return buf.Bytes(), err
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
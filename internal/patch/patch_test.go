@@ -0,0 +1,208 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPatch(t *testing.T) {
+	doc := `{"a":1,"b":{"c":[1,2,3]},"x/y":"slash","m~n":"tilde"}`
+
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+		err   bool
+	}{
+		{
+			name:  "add member",
+			patch: `[{"op":"add","path":"/d","value":4}]`,
+			want:  `{"a":1,"b":{"c":[1,2,3]},"d":4,"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "add to array",
+			patch: `[{"op":"add","path":"/b/c/1","value":9}]`,
+			want:  `{"a":1,"b":{"c":[1,9,2,3]},"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "append to array",
+			patch: `[{"op":"add","path":"/b/c/-","value":4}]`,
+			want:  `{"a":1,"b":{"c":[1,2,3,4]},"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "add null",
+			patch: `[{"op":"add","path":"/d","value":null}]`,
+			want:  `{"a":1,"b":{"c":[1,2,3]},"d":null,"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "add at root",
+			patch: `[{"op":"add","path":"","value":{"z":1}}]`,
+			want:  `{"z":1}`,
+		},
+		{
+			name:  "remove member",
+			patch: `[{"op":"remove","path":"/a"}]`,
+			want:  `{"b":{"c":[1,2,3]},"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "remove from array",
+			patch: `[{"op":"remove","path":"/b/c/0"}]`,
+			want:  `{"a":1,"b":{"c":[2,3]},"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "remove root",
+			patch: `[{"op":"remove","path":""}]`,
+			err:   true,
+		},
+		{
+			name:  "remove missing member",
+			patch: `[{"op":"remove","path":"/nope"}]`,
+			err:   true,
+		},
+		{
+			name:  "replace member",
+			patch: `[{"op":"replace","path":"/a","value":"one"}]`,
+			want:  `{"a":"one","b":{"c":[1,2,3]},"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "replace with null",
+			patch: `[{"op":"replace","path":"/a","value":null}]`,
+			want:  `{"a":null,"b":{"c":[1,2,3]},"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "replace root",
+			patch: `[{"op":"replace","path":"","value":[1]}]`,
+			want:  `[1]`,
+		},
+		{
+			name:  "replace missing value",
+			patch: `[{"op":"replace","path":"/a"}]`,
+			err:   true,
+		},
+		{
+			name:  "replace missing member",
+			patch: `[{"op":"replace","path":"/nope","value":1}]`,
+			err:   true,
+		},
+		{
+			name:  "move",
+			patch: `[{"op":"move","from":"/a","path":"/b/a"}]`,
+			want:  `{"b":{"a":1,"c":[1,2,3]},"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "move into own child",
+			patch: `[{"op":"move","from":"/b","path":"/b/c/0"}]`,
+			err:   true,
+		},
+		{
+			name:  "copy",
+			patch: `[{"op":"copy","from":"/b/c","path":"/d"}]`,
+			want:  `{"a":1,"b":{"c":[1,2,3]},"d":[1,2,3],"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "test passes",
+			patch: `[{"op":"test","path":"/b/c/2","value":3}]`,
+			want:  doc,
+		},
+		{
+			name:  "test null",
+			patch: `[{"op":"add","path":"/d","value":null},{"op":"test","path":"/d","value":null}]`,
+			want:  `{"a":1,"b":{"c":[1,2,3]},"d":null,"x/y":"slash","m~n":"tilde"}`,
+		},
+		{
+			name:  "test fails",
+			patch: `[{"op":"test","path":"/a","value":2}]`,
+			err:   true,
+		},
+		{
+			name:  "escaped slash",
+			patch: `[{"op":"replace","path":"/x~1y","value":"s"}]`,
+			want:  `{"a":1,"b":{"c":[1,2,3]},"x/y":"s","m~n":"tilde"}`,
+		},
+		{
+			name:  "escaped tilde",
+			patch: `[{"op":"remove","path":"/m~0n"}]`,
+			want:  `{"a":1,"b":{"c":[1,2,3]},"x/y":"slash"}`,
+		},
+		{
+			name:  "unknown op",
+			patch: `[{"op":"frobnicate","path":"/a"}]`,
+			err:   true,
+		},
+		{
+			name:  "bad pointer",
+			patch: `[{"op":"remove","path":"a"}]`,
+			err:   true,
+		},
+		{
+			name:  "failed op leaves nothing applied",
+			patch: `[{"op":"remove","path":"/a"},{"op":"remove","path":"/a"}]`,
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(doc), []byte(tt.patch))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func TestJSONPatchTestFailed(t *testing.T) {
+	_, err := JSONPatch([]byte(`{"a":1}`), []byte(`[{"op":"test","path":"/a","value":2}]`))
+	if !errors.Is(err, ErrTestFailed) {
+		t.Fatalf("got %v, want ErrTestFailed", err)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"replace member", `{"a":1,"b":2}`, `{"a":3}`, `{"a":3,"b":2}`},
+		{"remove member", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"nested", `{"a":{"b":1,"c":2}}`, `{"a":{"c":null,"d":3}}`, `{"a":{"b":1,"d":3}}`},
+		{"replace array", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"non-object patch", `{"a":1}`, `[1]`, `[1]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			assertJSON(t, got, tt.want)
+		})
+	}
+}
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result is not JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("bad expectation %q: %v", want, err)
+	}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
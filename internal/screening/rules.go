@@ -0,0 +1,198 @@
+package screening
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+// BannedWords matches reviews whose content or author contains a listed
+// word or phrase as a whole word, ignoring case.
+type BannedWords struct {
+	pattern *regexp.Regexp
+}
+
+// NewBannedWords compiles words into a single case-insensitive pattern.
+func NewBannedWords(words []string) BannedWords {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		word = strings.TrimSpace(word)
+		if word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	if len(quoted) == 0 {
+		return BannedWords{}
+	}
+	return BannedWords{pattern: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)}
+}
+
+func (r BannedWords) Name() string { return "banned_words" }
+
+func (r BannedWords) Check(review *data.Review) (Finding, error) {
+	if r.pattern == nil {
+		return Finding{}, nil
+	}
+	if r.pattern.MatchString(review.Author) {
+		return Finding{Matched: true, Field: "author", Message: "contains language that is not allowed"}, nil
+	}
+	if r.pattern.MatchString(review.Content) {
+		return Finding{Matched: true, Field: "content", Message: "contains language that is not allowed"}, nil
+	}
+	return Finding{}, nil
+}
+
+// linkPattern matches URLs and bare domain names
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|co|info|biz|xyz|ru|cn|ly|me)\b`)
+
+// Links matches reviews containing more than MaxLinks links.
+type Links struct {
+	MaxLinks int
+}
+
+func (r Links) Name() string { return "links" }
+
+func (r Links) Check(review *data.Review) (Finding, error) {
+	links := linkPattern.FindAllString(review.Content, -1)
+	if len(links) <= r.MaxLinks {
+		return Finding{}, nil
+	}
+	message := "must not contain links"
+	if r.MaxLinks > 0 {
+		message = fmt.Sprintf("must not contain more than %d links", r.MaxLinks)
+	}
+	return Finding{Matched: true, Field: "content", Message: message}, nil
+}
+
+// AllCaps matches reviews with at least MinLetters letters of which at
+// least Ratio are upper case.
+type AllCaps struct {
+	MinLetters int
+	Ratio      float64
+}
+
+func (r AllCaps) Name() string { return "all_caps" }
+
+func (r AllCaps) Check(review *data.Review) (Finding, error) {
+	letters, upper := 0, 0
+	for _, c := range review.Content {
+		if unicode.IsLetter(c) {
+			letters++
+			if unicode.IsUpper(c) {
+				upper++
+			}
+		}
+	}
+	if letters == 0 || letters < r.MinLetters || float64(upper)/float64(letters) < r.Ratio {
+		return Finding{}, nil
+	}
+	return Finding{Matched: true, Field: "content", Message: "must not be written mostly in capital letters"}, nil
+}
+
+// Repetition matches reviews that repeat one character more than
+// MaxCharRun times in a row ("soooooo", "!!!!!!"), or where a single word
+// makes up more than MaxWordShare of a review of five or more words.
+type Repetition struct {
+	MaxCharRun   int
+	MaxWordShare float64
+}
+
+func (r Repetition) Name() string { return "repetition" }
+
+func (r Repetition) Check(review *data.Review) (Finding, error) {
+	matched := Finding{Matched: true, Field: "content", Message: "must not be repetitive"}
+
+	if r.MaxCharRun > 0 {
+		var last rune
+		run := 0
+		for _, c := range review.Content {
+			if c == last && !unicode.IsSpace(c) {
+				run++
+			} else {
+				last, run = c, 1
+			}
+			if run > r.MaxCharRun {
+				return matched, nil
+			}
+		}
+	}
+
+	if r.MaxWordShare > 0 {
+		words := normalizedWords(review.Content)
+		if len(words) >= 5 {
+			counts := make(map[string]int)
+			for _, word := range words {
+				counts[word]++
+				if float64(counts[word])/float64(len(words)) > r.MaxWordShare {
+					return matched, nil
+				}
+			}
+		}
+	}
+
+	return Finding{}, nil
+}
+
+// Duplicates matches reviews whose content is the same as, or at least
+// Similarity alike to, one of the author's Lookback most recent reviews.
+// Similarity is the Jaccard index of the two reviews' word sets.
+type Duplicates struct {
+	History    History
+	Lookback   int
+	Similarity float64
+}
+
+func (r Duplicates) Name() string { return "duplicates" }
+
+func (r Duplicates) Check(review *data.Review) (Finding, error) {
+	if r.History == nil || r.Lookback <= 0 {
+		return Finding{}, nil
+	}
+
+	recent, err := r.History.RecentByAuthor(review.Author, review.ID, r.Lookback)
+	if err != nil {
+		return Finding{}, err
+	}
+
+	words := wordSet(review.Content)
+	for _, content := range recent {
+		if jaccard(words, wordSet(content)) >= r.Similarity {
+			return Finding{Matched: true, Field: "content", Message: "duplicates one of this author's recent reviews"}, nil
+		}
+	}
+	return Finding{}, nil
+}
+
+// normalizedWords splits s into lower-case words, dropping punctuation
+func normalizedWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+}
+
+// wordSet returns the distinct normalized words of s
+func wordSet(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	for _, word := range normalizedWords(s) {
+		set[word] = struct{}{}
+	}
+	return set
+}
+
+// jaccard returns the size of the intersection of a and b over the size
+// of their union. Two empty sets are identical.
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for word := range a {
+		if _, ok := b[word]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
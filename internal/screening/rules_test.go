@@ -0,0 +1,204 @@
+package screening
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+func TestBannedWords(t *testing.T) {
+	rule := NewBannedWords([]string{"darn", " heck ", "", "a.b"})
+
+	tests := []struct {
+		name    string
+		author  string
+		content string
+		field   string
+	}{
+		{name: "clean", author: "Sam", content: "A fine product"},
+		{name: "in content", author: "Sam", content: "What the HECK is this", field: "content"},
+		{name: "in author", author: "darn fan", content: "A fine product", field: "author"},
+		{name: "inside another word", author: "Sam", content: "Good for darning socks"},
+		{name: "phrase with punctuation", author: "Sam", content: "see a.b now", field: "content"},
+		{name: "punctuation is literal", author: "Sam", content: "see axb now"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finding, err := rule.Check(&data.Review{Author: tt.author, Content: tt.content})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if finding.Matched != (tt.field != "") || finding.Field != tt.field {
+				t.Errorf("got %+v, want a match on %q", finding, tt.field)
+			}
+		})
+	}
+}
+
+func TestBannedWordsWithoutWords(t *testing.T) {
+	finding, err := NewBannedWords([]string{" ", ""}).Check(&data.Review{Content: "anything at all"})
+	if err != nil || finding.Matched {
+		t.Errorf("got %+v, %v; want no match", finding, err)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxLinks int
+		content  string
+		message  string
+	}{
+		{name: "no links", content: "great product"},
+		{name: "url", content: "see https://example.com/x", message: "must not contain links"},
+		{name: "www", content: "visit www.example for more", message: "must not contain links"},
+		{name: "bare domain", content: "buy it at shop.example.io", message: "must not contain links"},
+		{name: "abbreviation", content: "e.g. it works"},
+		{name: "at the limit", maxLinks: 2, content: "a.com and b.org"},
+		{name: "over the limit", maxLinks: 2, content: "a.com, b.org and c.net", message: "must not contain more than 2 links"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finding, err := Links{MaxLinks: tt.maxLinks}.Check(&data.Review{Content: tt.content})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if finding.Matched != (tt.message != "") || finding.Message != tt.message {
+				t.Errorf("got %+v, want message %q", finding, tt.message)
+			}
+		})
+	}
+}
+
+func TestAllCaps(t *testing.T) {
+	rule := AllCaps{MinLetters: 10, Ratio: 0.7}
+
+	tests := []struct {
+		name    string
+		content string
+		matched bool
+	}{
+		{name: "too short", content: "WOW"},
+		{name: "no letters", content: "!!!! 1234"},
+		{name: "minimum letters", content: "ABCDEFGHIJ", matched: true},
+		{name: "at the ratio", content: "ABCDEFGhij", matched: true},
+		{name: "under the ratio", content: "ABCDEFghij"},
+		{name: "punctuation ignored", content: "GREAT PRODUCT!!!", matched: true},
+		{name: "sentence case", content: "Great product, would buy again"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finding, err := rule.Check(&data.Review{Content: tt.content})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if finding.Matched != tt.matched {
+				t.Errorf("got %+v, want matched %v", finding, tt.matched)
+			}
+		})
+	}
+}
+
+func TestRepetition(t *testing.T) {
+	rule := Repetition{MaxCharRun: 3, MaxWordShare: 0.5}
+
+	tests := []struct {
+		name    string
+		content string
+		matched bool
+	}{
+		{name: "clean", content: "this is a fine product"},
+		{name: "run at the limit", content: "sooo good"},
+		{name: "run over the limit", content: "soooo good", matched: true},
+		{name: "punctuation run", content: "great!!!!", matched: true},
+		{name: "spaces don't run", content: "fine     product"},
+		{name: "word at the share", content: "buy this buy that buy now"},
+		{name: "word over the share", content: "buy buy buy buy now", matched: true},
+		{name: "too few words", content: "buy buy buy buy"},
+		{name: "words normalized", content: "Buy! buy, BUY. buy now", matched: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finding, err := rule.Check(&data.Review{Content: tt.content})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if finding.Matched != tt.matched {
+				t.Errorf("got %+v, want matched %v", finding, tt.matched)
+			}
+		})
+	}
+}
+
+// history is a History of each author's reviews, most recent first
+type history map[string][]string
+
+func (h history) RecentByAuthor(author string, excludeID int64, limit int) ([]string, error) {
+	recent := h[author]
+	if len(recent) > limit {
+		recent = recent[:limit]
+	}
+	return recent, nil
+}
+
+func TestDuplicates(t *testing.T) {
+	rule := Duplicates{
+		History: history{
+			"ann": {"Great product, works well", "one two three four five"},
+			"bob": {"an older review", "something else", "what a lovely lamp"},
+		},
+		Lookback:   2,
+		Similarity: 0.8,
+	}
+
+	tests := []struct {
+		name    string
+		author  string
+		content string
+		matched bool
+	}{
+		{name: "same content", author: "ann", content: "Great product, works well", matched: true},
+		{name: "same words", author: "ann", content: "works well. GREAT product!", matched: true},
+		{name: "at the similarity", author: "ann", content: "one two three four", matched: true},
+		{name: "under the similarity", author: "ann", content: "one two three four six"},
+		{name: "another author's review", author: "carol", content: "Great product, works well"},
+		{name: "beyond the lookback", author: "bob", content: "what a lovely lamp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finding, err := rule.Check(&data.Review{Author: tt.author, Content: tt.content})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if finding.Matched != tt.matched {
+				t.Errorf("got %+v, want matched %v", finding, tt.matched)
+			}
+		})
+	}
+}
+
+type failingHistory struct{}
+
+func (failingHistory) RecentByAuthor(string, int64, int) ([]string, error) {
+	return nil, errors.New("history unavailable")
+}
+
+func TestDuplicatesHistoryError(t *testing.T) {
+	rule := Duplicates{History: failingHistory{}, Lookback: 1, Similarity: 0.9}
+	_, err := rule.Check(&data.Review{Author: "ann", Content: "hello"})
+	if err == nil {
+		t.Fatal("expected the history error")
+	}
+
+	rule.Lookback = 0
+	_, err = rule.Check(&data.Review{Author: "ann", Content: "hello"})
+	if err != nil {
+		t.Fatalf("a rule with no lookback shouldn't read history, got %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+// Package screening runs automatic checks over a review before it is
+// saved. Each rule looks for one kind of problem; the pipeline decides,
+// per rule, whether a match rejects the review, holds it for a moderator
+// or only adds to its score.
+package screening
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+	"github.com/RayMC17/AWT_Test1/internal/validator"
+)
+
+// Action is what the pipeline does when a rule matches.
+type Action string
+
+const (
+	// ActionReject fails the request with the rule's validation error.
+	ActionReject Action = "reject"
+	// ActionHold saves the review as pending for a moderator.
+	ActionHold Action = "hold"
+	// ActionScore only adds the rule's score to the review.
+	ActionScore Action = "score"
+)
+
+// Finding is what a rule reports about a review. Field and Message are
+// used as the validation error when the rule's action is reject.
+type Finding struct {
+	Matched bool
+	Field   string
+	Message string
+}
+
+// Rule checks a review for one kind of problem.
+type Rule interface {
+	Name() string
+	Check(review *data.Review) (Finding, error)
+}
+
+// History gives the duplicate rule an author's recent review content.
+// data.ReviewModel implements it.
+type History interface {
+	RecentByAuthor(author string, excludeID int64, limit int) ([]string, error)
+}
+
+// Step is a rule together with the action and score applied when it matches.
+type Step struct {
+	Rule   Rule
+	Action Action
+	Score  float64
+}
+
+// Result is the outcome of screening one review. Errors holds the
+// validation errors of rejecting rules; Flags names every rule that matched.
+type Result struct {
+	Hold   bool
+	Score  float64
+	Flags  []string
+	Errors map[string]string
+}
+
+// Rejected reports whether any rejecting rule matched.
+func (r *Result) Rejected() bool {
+	return len(r.Errors) > 0
+}
+
+// Pipeline runs its steps in order over a review.
+type Pipeline struct {
+	Steps []Step
+	// HoldScore holds a review whose total score reaches it, even if no
+	// single rule holds it. Zero disables the threshold.
+	HoldScore float64
+}
+
+// Screen runs every step over review. An error means a rule could not
+// run, not that the review failed screening.
+func (p *Pipeline) Screen(review *data.Review) (*Result, error) {
+	v := validator.New()
+	result := &Result{}
+
+	for _, step := range p.Steps {
+		finding, err := step.Rule.Check(review)
+		if err != nil {
+			return nil, fmt.Errorf("screening rule %s: %w", step.Rule.Name(), err)
+		}
+		if !finding.Matched {
+			continue
+		}
+
+		result.Flags = append(result.Flags, step.Rule.Name())
+		result.Score += step.Score
+		switch step.Action {
+		case ActionReject:
+			v.AddError(finding.Field, finding.Message)
+		case ActionHold:
+			result.Hold = true
+		}
+	}
+
+	if p.HoldScore > 0 && result.Score >= p.HoldScore {
+		result.Hold = true
+	}
+	result.Errors = v.Errors
+	return result, nil
+}
+
+// RuleConfig is the part of a rule's configuration common to all rules.
+type RuleConfig struct {
+	Enabled bool    `json:"enabled"`
+	Action  Action  `json:"action"`
+	Score   float64 `json:"score"`
+}
+
+// Config configures the built-in rules. It is read from a JSON file with
+// the same shape; members left out keep their DefaultConfig values.
+type Config struct {
+	HoldScore   float64 `json:"hold_score"`
+	BannedWords struct {
+		RuleConfig
+		Words []string `json:"words"`
+	} `json:"banned_words"`
+	Links struct {
+		RuleConfig
+		MaxLinks int `json:"max_links"`
+	} `json:"links"`
+	AllCaps struct {
+		RuleConfig
+		MinLetters int     `json:"min_letters"`
+		Ratio      float64 `json:"ratio"`
+	} `json:"all_caps"`
+	Repetition struct {
+		RuleConfig
+		MaxCharRun   int     `json:"max_char_run"`
+		MaxWordShare float64 `json:"max_word_share"`
+	} `json:"repetition"`
+	Duplicates struct {
+		RuleConfig
+		Lookback   int     `json:"lookback"`
+		Similarity float64 `json:"similarity"`
+	} `json:"duplicates"`
+}
+
+// DefaultConfig returns the configuration used when no file is given:
+// banned words reject, links and duplicates hold, and shouting or
+// repetition only score, holding a review when both match.
+func DefaultConfig() Config {
+	var cfg Config
+	cfg.HoldScore = 1
+
+	cfg.BannedWords.RuleConfig = RuleConfig{Enabled: true, Action: ActionReject, Score: 1}
+
+	cfg.Links.RuleConfig = RuleConfig{Enabled: true, Action: ActionHold, Score: 0.5}
+
+	cfg.AllCaps.RuleConfig = RuleConfig{Enabled: true, Action: ActionScore, Score: 0.5}
+	cfg.AllCaps.MinLetters = 20
+	cfg.AllCaps.Ratio = 0.7
+
+	cfg.Repetition.RuleConfig = RuleConfig{Enabled: true, Action: ActionScore, Score: 0.5}
+	cfg.Repetition.MaxCharRun = 5
+	cfg.Repetition.MaxWordShare = 0.5
+
+	cfg.Duplicates.RuleConfig = RuleConfig{Enabled: true, Action: ActionHold, Score: 1}
+	cfg.Duplicates.Lookback = 20
+	cfg.Duplicates.Similarity = 0.9
+
+	return cfg
+}
+
+// LoadConfig reads a JSON configuration file over DefaultConfig. An empty
+// path returns the defaults.
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(body, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("screening config %s: %w", path, err)
+	}
+	return cfg, cfg.validate()
+}
+
+// validate checks every action is one the pipeline knows
+func (cfg Config) validate() error {
+	for name, rc := range map[string]RuleConfig{
+		"banned_words": cfg.BannedWords.RuleConfig,
+		"links":        cfg.Links.RuleConfig,
+		"all_caps":     cfg.AllCaps.RuleConfig,
+		"repetition":   cfg.Repetition.RuleConfig,
+		"duplicates":   cfg.Duplicates.RuleConfig,
+	} {
+		switch rc.Action {
+		case ActionReject, ActionHold, ActionScore:
+		default:
+			return fmt.Errorf("screening config: %s: unknown action %q", name, rc.Action)
+		}
+	}
+	return nil
+}
+
+// New builds a pipeline from cfg. history is used by the duplicate rule.
+func New(cfg Config, history History) *Pipeline {
+	p := &Pipeline{HoldScore: cfg.HoldScore}
+
+	add := func(rc RuleConfig, rule Rule) {
+		if rc.Enabled {
+			p.Steps = append(p.Steps, Step{Rule: rule, Action: rc.Action, Score: rc.Score})
+		}
+	}
+
+	if len(cfg.BannedWords.Words) > 0 {
+		add(cfg.BannedWords.RuleConfig, NewBannedWords(cfg.BannedWords.Words))
+	}
+	add(cfg.Links.RuleConfig, Links{MaxLinks: cfg.Links.MaxLinks})
+	add(cfg.AllCaps.RuleConfig, AllCaps{MinLetters: cfg.AllCaps.MinLetters, Ratio: cfg.AllCaps.Ratio})
+	add(cfg.Repetition.RuleConfig, Repetition{MaxCharRun: cfg.Repetition.MaxCharRun, MaxWordShare: cfg.Repetition.MaxWordShare})
+	add(cfg.Duplicates.RuleConfig, Duplicates{
+		History:    history,
+		Lookback:   cfg.Duplicates.Lookback,
+		Similarity: cfg.Duplicates.Similarity,
+	})
+
+	return p
+}
@@ -0,0 +1,168 @@
+package screening
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+// stubRule matches, or doesn't, regardless of the review
+type stubRule struct {
+	name    string
+	matched bool
+	err     error
+}
+
+func (r stubRule) Name() string { return r.name }
+
+func (r stubRule) Check(review *data.Review) (Finding, error) {
+	return Finding{Matched: r.matched, Field: "content", Message: r.name}, r.err
+}
+
+func TestPipelineScreen(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []Step
+		holdScore float64
+		hold      bool
+		score     float64
+		flags     []string
+		errors    map[string]string
+	}{
+		{
+			name:      "nothing matches",
+			steps:     []Step{{Rule: stubRule{name: "a"}, Action: ActionReject, Score: 1}},
+			holdScore: 1,
+		},
+		{
+			name:      "reject",
+			steps:     []Step{{Rule: stubRule{name: "a", matched: true}, Action: ActionReject, Score: 1}},
+			holdScore: 5,
+			score:     1,
+			flags:     []string{"a"},
+			errors:    map[string]string{"content": "a"},
+		},
+		{
+			name:      "hold",
+			steps:     []Step{{Rule: stubRule{name: "a", matched: true}, Action: ActionHold}},
+			holdScore: 1,
+			hold:      true,
+			flags:     []string{"a"},
+		},
+		{
+			name: "score under the threshold",
+			steps: []Step{
+				{Rule: stubRule{name: "a", matched: true}, Action: ActionScore, Score: 0.5},
+				{Rule: stubRule{name: "b"}, Action: ActionScore, Score: 0.5},
+			},
+			holdScore: 1,
+			score:     0.5,
+			flags:     []string{"a"},
+		},
+		{
+			name: "score at the threshold",
+			steps: []Step{
+				{Rule: stubRule{name: "a", matched: true}, Action: ActionScore, Score: 0.5},
+				{Rule: stubRule{name: "b", matched: true}, Action: ActionScore, Score: 0.5},
+			},
+			holdScore: 1,
+			hold:      true,
+			score:     1,
+			flags:     []string{"a", "b"},
+		},
+		{
+			name: "no threshold",
+			steps: []Step{
+				{Rule: stubRule{name: "a", matched: true}, Action: ActionScore, Score: 0.5},
+				{Rule: stubRule{name: "b", matched: true}, Action: ActionScore, Score: 0.5},
+			},
+			score: 1,
+			flags: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Pipeline{Steps: tt.steps, HoldScore: tt.holdScore}
+			result, err := p.Screen(&data.Review{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Hold != tt.hold || result.Score != tt.score || !reflect.DeepEqual(result.Flags, tt.flags) {
+				t.Errorf("got hold %v, score %v, flags %v; want %v, %v, %v",
+					result.Hold, result.Score, result.Flags, tt.hold, tt.score, tt.flags)
+			}
+			if result.Rejected() != (tt.errors != nil) || (tt.errors != nil && !reflect.DeepEqual(result.Errors, tt.errors)) {
+				t.Errorf("got errors %v, want %v", result.Errors, tt.errors)
+			}
+		})
+	}
+}
+
+func TestPipelineScreenRuleError(t *testing.T) {
+	p := &Pipeline{Steps: []Step{{Rule: stubRule{name: "a", err: errors.New("boom")}, Action: ActionHold}}}
+	_, err := p.Screen(&data.Review{})
+	if err == nil {
+		t.Fatal("expected the rule's error")
+	}
+}
+
+func TestDefaultPipeline(t *testing.T) {
+	p := New(DefaultConfig(), nil)
+
+	tests := []struct {
+		name    string
+		content string
+		hold    bool
+		flags   []string
+	}{
+		{name: "clean", content: "A solid lamp, bright and easy to set up."},
+		{name: "shouting only scores", content: "THIS PRODUCT IS REALLY GREAT AND I LOVE IT", flags: []string{"all_caps"}},
+		{name: "shouting and repetition hold", content: "THIS IS SOOOOOOOO GOOD I LOVE IT", hold: true, flags: []string{"all_caps", "repetition"}},
+		{name: "a link holds", content: "Cheaper at https://example.com", hold: true, flags: []string{"links"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := p.Screen(&data.Review{Author: "Sam", Content: tt.content})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if result.Hold != tt.hold || !reflect.DeepEqual(result.Flags, tt.flags) || result.Rejected() {
+				t.Errorf("got hold %v, flags %v, errors %v; want hold %v, flags %v",
+					result.Hold, result.Flags, result.Errors, tt.hold, tt.flags)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "screening.json")
+	err := os.WriteFile(path, []byte(`{"links": {"enabled": true, "action": "reject", "max_links": 1}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Links.Action != ActionReject || cfg.Links.MaxLinks != 1 || cfg.AllCaps.MinLetters != 20 {
+		t.Errorf("got %+v, want the links rule overridden over the defaults", cfg)
+	}
+
+	path = filepath.Join(dir, "bad.json")
+	err = os.WriteFile(path, []byte(`{"links": {"action": "ban"}}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig(path)
+	if err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+}
@@ -0,0 +1,30 @@
+// Package storage keeps uploaded files out of the database. Handlers only
+// see the Store interface; Disk, which writes under a local directory, is
+// the default backend.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned by Open when no file is stored under the key.
+var ErrNotFound = errors.New("file not found")
+
+// ErrInvalidKey is returned for keys that would escape the store, such as
+// absolute paths or ones containing "..".
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Store saves and serves files by key. Keys are slash-separated relative
+// paths such as "reviews/12/ab34.jpg".
+type Store interface {
+	// Put stores body under key, replacing any file already there
+	Put(ctx context.Context, key string, contentType string, body io.Reader) error
+	// Open returns the file stored under key, or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file stored under key. Deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients can fetch the file stored under key
+	URL(key string) string
+}
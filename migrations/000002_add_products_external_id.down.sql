@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS products_external_id_idx;
+
+ALTER TABLE products DROP COLUMN IF EXISTS external_id;
@@ -0,0 +1,3 @@
+ALTER TABLE products ADD COLUMN external_id VARCHAR(100);
+
+CREATE UNIQUE INDEX products_external_id_idx ON products (external_id);
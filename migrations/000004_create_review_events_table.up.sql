@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS review_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(50) NOT NULL,
+    product_id BIGINT NOT NULL,
+    review_id BIGINT,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS review_events_product_id_idx ON review_events (product_id, id);
+CREATE INDEX IF NOT EXISTS review_events_created_at_idx ON review_events (created_at);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS reviews_status_idx;
+ALTER TABLE reviews DROP CONSTRAINT IF EXISTS reviews_status_check;
+ALTER TABLE reviews DROP COLUMN IF EXISTS moderated_at;
+ALTER TABLE reviews DROP COLUMN IF EXISTS moderated_by;
+ALTER TABLE reviews DROP COLUMN IF EXISTS moderation_reason;
+ALTER TABLE reviews DROP COLUMN IF EXISTS status;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS reviews_author_created_at_idx;
+ALTER TABLE reviews DROP COLUMN IF EXISTS screening_flags;
+ALTER TABLE reviews DROP COLUMN IF EXISTS screening_score;
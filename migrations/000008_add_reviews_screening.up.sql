@@ -0,0 +1,4 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS screening_score REAL NOT NULL DEFAULT 0;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS screening_flags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS reviews_author_created_at_idx ON reviews (author, created_at);
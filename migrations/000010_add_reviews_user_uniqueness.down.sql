@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS reviews_product_author_key;
+DROP INDEX IF EXISTS reviews_product_user_key;
+ALTER TABLE reviews DROP COLUMN IF EXISTS user_id;
+
+-- put back the reviews the up migration rejected as duplicates
+UPDATE reviews r
+SET status = d.status, moderation_reason = d.moderation_reason, moderated_by = d.moderated_by, moderated_at = d.moderated_at
+FROM reviews_deduplicated d
+WHERE r.id = d.review_id AND r.moderation_reason = 'duplicate review by the same author';
+
+UPDATE products p
+SET average_rating = (
+    SELECT COALESCE(AVG(rating), 0)
+    FROM reviews
+    WHERE product_id = p.id AND status = 'approved'
+);
+
+DROP TABLE IF EXISTS reviews_deduplicated;
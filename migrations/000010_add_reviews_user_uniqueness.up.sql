@@ -0,0 +1,60 @@
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS user_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+-- keep each author's newest review on a product and reject the older
+-- duplicates so the unique index below can be built. The rejected reviews
+-- and what they were are kept in reviews_deduplicated so the down migration
+-- can put them back.
+CREATE TABLE IF NOT EXISTS reviews_deduplicated (
+    review_id BIGINT PRIMARY KEY REFERENCES reviews(id) ON DELETE CASCADE,
+    kept_review_id BIGINT NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    moderation_reason TEXT,
+    moderated_by BIGINT,
+    moderated_at TIMESTAMP WITH TIME ZONE
+);
+
+INSERT INTO reviews_deduplicated (review_id, kept_review_id, status, moderation_reason, moderated_by, moderated_at)
+SELECT r.id, ranked.kept, r.status, r.moderation_reason, r.moderated_by, r.moderated_at
+FROM reviews r
+JOIN (
+    SELECT id,
+        ROW_NUMBER() OVER w AS n,
+        FIRST_VALUE(id) OVER w AS kept
+    FROM reviews
+    WHERE status <> 'rejected'
+    WINDOW w AS (
+        PARTITION BY product_id, LOWER(author)
+        ORDER BY created_at DESC, id DESC
+    )
+) ranked ON r.id = ranked.id
+WHERE ranked.n > 1;
+
+UPDATE reviews r
+SET status = 'rejected', moderation_reason = 'duplicate review by the same author', moderated_by = NULL, moderated_at = NOW()
+FROM reviews_deduplicated d
+WHERE r.id = d.review_id;
+
+DO $$
+DECLARE
+    rejected BIGINT;
+BEGIN
+    SELECT COUNT(*) INTO rejected FROM reviews_deduplicated;
+    IF rejected > 0 THEN
+        RAISE WARNING '% duplicate reviews were rejected; see reviews_deduplicated', rejected;
+    END IF;
+END $$;
+
+UPDATE products p
+SET average_rating = (
+    SELECT COALESCE(AVG(rating), 0)
+    FROM reviews
+    WHERE product_id = p.id AND status = 'approved'
+);
+
+-- signed-in reviewers get one review per product; anonymous reviews are
+-- told apart by author name. Rejected reviews don't count, so an author
+-- whose review was rejected can write a new one.
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_product_user_key
+    ON reviews (product_id, user_id) WHERE user_id IS NOT NULL AND status <> 'rejected';
+CREATE UNIQUE INDEX IF NOT EXISTS reviews_product_author_key
+    ON reviews (product_id, LOWER(author)) WHERE user_id IS NULL AND status <> 'rejected';
@@ -0,0 +1,2 @@
+DELETE FROM permissions WHERE code = 'products:respond';
+DROP TABLE IF EXISTS review_responses;
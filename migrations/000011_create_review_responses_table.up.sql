@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS review_responses (
+    id BIGSERIAL PRIMARY KEY,
+    review_id BIGINT NOT NULL UNIQUE REFERENCES reviews(id) ON DELETE CASCADE,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO permissions (code) VALUES ('products:respond') ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,2 @@
+ALTER TABLE reviews DROP COLUMN IF EXISTS edited;
+DROP TABLE IF EXISTS revisions;
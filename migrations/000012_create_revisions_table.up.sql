@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS revisions (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('product', 'review')),
+    record_id BIGINT NOT NULL,
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    previous JSONB NOT NULL,
+    changes JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS revisions_record_idx ON revisions (kind, record_id, id);
+
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS edited BOOLEAN NOT NULL DEFAULT FALSE;
+-- reviews are created with matching timestamps, so a later updated_at means an edit
+UPDATE reviews SET edited = TRUE WHERE updated_at > created_at;
@@ -0,0 +1,17 @@
+DELETE FROM permissions WHERE code = 'catalog:restore';
+
+-- soft-deleted rows would otherwise reappear
+DELETE FROM reviews WHERE deleted_at IS NOT NULL;
+DELETE FROM products WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS reviews_product_user_key;
+DROP INDEX IF EXISTS reviews_product_author_key;
+CREATE UNIQUE INDEX reviews_product_user_key
+    ON reviews (product_id, user_id) WHERE user_id IS NOT NULL AND status <> 'rejected';
+CREATE UNIQUE INDEX reviews_product_author_key
+    ON reviews (product_id, LOWER(author)) WHERE user_id IS NULL AND status <> 'rejected';
+
+DROP INDEX IF EXISTS reviews_deleted_at_idx;
+DROP INDEX IF EXISTS products_deleted_at_idx;
+ALTER TABLE reviews DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,15 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS products_deleted_at_idx ON products (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS reviews_deleted_at_idx ON reviews (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- a deleted review no longer stops its author writing another
+DROP INDEX IF EXISTS reviews_product_user_key;
+DROP INDEX IF EXISTS reviews_product_author_key;
+CREATE UNIQUE INDEX reviews_product_user_key
+    ON reviews (product_id, user_id) WHERE user_id IS NOT NULL AND status <> 'rejected' AND deleted_at IS NULL;
+CREATE UNIQUE INDEX reviews_product_author_key
+    ON reviews (product_id, LOWER(author)) WHERE user_id IS NULL AND status <> 'rejected' AND deleted_at IS NULL;
+
+INSERT INTO permissions (code) VALUES ('catalog:restore') ON CONFLICT (code) DO NOTHING;
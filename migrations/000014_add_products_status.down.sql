@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code = 'products:edit';
+
+DROP INDEX IF EXISTS products_status_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS status;
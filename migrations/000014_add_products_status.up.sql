@@ -0,0 +1,6 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+    CHECK (status IN ('draft', 'active', 'archived'));
+
+CREATE INDEX IF NOT EXISTS products_status_idx ON products (status);
+
+INSERT INTO permissions (code) VALUES ('products:edit') ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1,4 @@
+UPDATE products SET image_url = '' WHERE LENGTH(image_url) > 255;
+ALTER TABLE products ALTER COLUMN image_url TYPE VARCHAR(255);
+
+DROP TABLE IF EXISTS product_images;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS reviews_variant_id_idx;
+ALTER TABLE reviews DROP COLUMN IF EXISTS variant_id;
+DROP TABLE IF EXISTS product_variants;
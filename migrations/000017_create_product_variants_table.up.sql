@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS product_variants (
+    id BIGSERIAL PRIMARY KEY,
+    product_id BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    sku TEXT NOT NULL UNIQUE,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    -- in minor units; NULL when the variant sells at the product's price
+    price BIGINT CHECK (price >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_variants_product_id_idx ON product_variants (product_id);
+
+-- a review can name the variant it is about; deleting the variant keeps the review
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS variant_id BIGINT REFERENCES product_variants(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS reviews_variant_id_idx ON reviews (variant_id);
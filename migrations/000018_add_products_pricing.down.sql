@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS products_price_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS availability;
+ALTER TABLE products DROP COLUMN IF EXISTS currency;
+ALTER TABLE products DROP COLUMN IF EXISTS price;
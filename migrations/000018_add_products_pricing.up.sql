@@ -0,0 +1,8 @@
+-- price is in minor units of currency, an ISO 4217 code
+ALTER TABLE products ADD COLUMN IF NOT EXISTS price BIGINT NOT NULL DEFAULT 0 CHECK (price >= 0);
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD'
+    CHECK (currency ~ '^[A-Z]{3}$');
+ALTER TABLE products ADD COLUMN IF NOT EXISTS availability TEXT NOT NULL DEFAULT 'in_stock'
+    CHECK (availability IN ('in_stock', 'out_of_stock', 'preorder', 'discontinued'));
+
+CREATE INDEX IF NOT EXISTS products_price_idx ON products (price);
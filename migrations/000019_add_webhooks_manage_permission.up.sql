@@ -0,0 +1 @@
+INSERT INTO permissions (code) VALUES ('webhooks:manage') ON CONFLICT (code) DO NOTHING;
@@ -0,0 +1 @@
+-- the redacted review content can't be restored
@@ -0,0 +1,8 @@
+-- events about reviews that aren't approved only carry the review's IDs
+UPDATE review_events
+SET data = jsonb_build_object('id', data->'id', 'product_id', data->'product_id')
+WHERE type LIKE 'review.%' AND data ? 'status' AND data->>'status' <> 'approved';
+
+UPDATE outbox
+SET payload = jsonb_build_object('id', payload->'id', 'product_id', payload->'product_id')
+WHERE type LIKE 'review.%' AND payload ? 'status' AND payload->>'status' <> 'approved';
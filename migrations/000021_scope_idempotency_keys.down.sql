@@ -0,0 +1,2 @@
+DELETE FROM idempotency_keys;
+ALTER TABLE idempotency_keys ALTER COLUMN key TYPE VARCHAR(255);
@@ -0,0 +1,3 @@
+-- keys are now stored as "<user id>:<Idempotency-Key>"
+DELETE FROM idempotency_keys;
+ALTER TABLE idempotency_keys ALTER COLUMN key TYPE VARCHAR(300);
@@ -0,0 +1,8 @@
+-- one helpful vote per user and review; helpful_count keeps the total,
+-- including the anonymous votes cast before this table existed
+CREATE TABLE IF NOT EXISTS review_helpful_votes (
+    review_id BIGINT NOT NULL REFERENCES reviews(id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (review_id, user_id)
+);
@@ -0,0 +1,290 @@
+// Package client is a Go client for the products and reviews API.
+//
+//	c := client.New("http://localhost:4000")
+//	it := c.Products.List(ctx, client.Filters{Category: "books"})
+//	for it.Next() {
+//		fmt.Println(it.Item().Name)
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle error
+//	}
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RayMC17/AWT_Test1/internal/data"
+)
+
+// Product, Review and Report are the API's own model types.
+type (
+	Product = data.Product
+	Review  = data.Review
+	Report  = data.Report
+)
+
+// Filters narrows and orders a list request. Limit is the page size used
+// while iterating and Offset is where iteration starts.
+type Filters struct {
+	Sort      string // products: rating, date or price; reviews: helpful or date
+	Limit     int
+	Offset    int
+	Name      string // products: substring match on the name
+	Category  string // products: exact category match
+	Status    string // products: draft, active or archived; active when empty
+	MinPrice  *int64 // products: lowest price in minor units
+	MaxPrice  *int64 // products: highest price in minor units
+	InStock   *bool  // products: true for only in-stock products, false for only the rest
+	ProductID int64  // reviews: only reviews for this product
+	VariantID int64  // reviews: only reviews of this product variant
+}
+
+// Client talks to one API server. Its zero value is not usable; call New.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+
+	// MaxRetries is how many times a request is retried after a 429, or
+	// after a 5xx or a network error if it is safe to send again. POSTs are
+	// only safe to send again to endpoints that de-duplicate them by
+	// Idempotency-Key, and only for a client created WithToken, since the
+	// server ignores the keys of anonymous callers. Defaults to 3.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// retries. A Retry-After header from the server takes precedence.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	Products *ProductsService
+	Reviews  *ReviewsService
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the default http.Client
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithToken authenticates every request with a bearer token from
+// POST /v1/tokens/authentication
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries sets how many times a failed request is retried
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.MaxRetries = n
+	}
+}
+
+// New returns a client for the API at baseURL, e.g. http://localhost:4000
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 3,
+		MinBackoff: 250 * time.Millisecond,
+		MaxBackoff: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.Products = &ProductsService{client: c}
+	c.Reviews = &ReviewsService{client: c}
+	return c
+}
+
+// request describes a single API call
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	body        any
+	contentType string
+	idempotent  bool // safe to retry
+	keyed       bool // a POST the server de-duplicates by Idempotency-Key
+}
+
+// do sends req, retrying where it is safe to, and decodes a successful
+// response into out. Error responses are returned as *Error.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		payload, err = json.Marshal(req.body)
+		if err != nil {
+			return err
+		}
+	}
+
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+
+	// a keyed POST carries a fresh key so the server can de-duplicate
+	// retries; any other POST could take effect twice, so isn't retried
+	var idempotencyKey string
+	if req.keyed && c.token != "" {
+		idempotencyKey = newIdempotencyKey()
+		req.idempotent = true
+	}
+
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, u, body)
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Accept", "application/json, application/problem+json")
+		if payload != nil {
+			contentType := req.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			httpReq.Header.Set("Content-Type", contentType)
+		}
+		if idempotencyKey != "" {
+			httpReq.Header.Set("Idempotency-Key", idempotencyKey)
+		}
+		if c.token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+c.token)
+		}
+
+		res, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			if ctx.Err() != nil || !req.idempotent || attempt >= c.MaxRetries {
+				return err
+			}
+			if err := c.sleep(ctx, attempt, ""); err != nil {
+				return err
+			}
+			continue
+		}
+
+		retryable := res.StatusCode == http.StatusTooManyRequests ||
+			(res.StatusCode >= 500 && req.idempotent)
+		if retryable && attempt < c.MaxRetries {
+			retryAfter := res.Header.Get("Retry-After")
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			if err := c.sleep(ctx, attempt, retryAfter); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return decodeResponse(res, out)
+	}
+}
+
+// sleep waits before the next attempt, honouring Retry-After when given
+// and otherwise backing off exponentially with jitter
+func (c *Client) sleep(ctx context.Context, attempt int, retryAfter string) error {
+	wait := c.MinBackoff << attempt
+	if wait <= 0 || wait > c.MaxBackoff {
+		wait = c.MaxBackoff
+	}
+	wait = wait/2 + time.Duration(mathrand.Int63n(int64(wait/2)+1))
+
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func decodeResponse(res *http.Response, out any) error {
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		apiErr := &Error{Status: res.StatusCode}
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+		err := json.Unmarshal(body, apiErr)
+		if err != nil || apiErr.Code == "" {
+			apiErr.Detail = strings.TrimSpace(string(body))
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		_, err := io.Copy(io.Discard, res.Body)
+		return err
+	}
+	err := json.NewDecoder(res.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("client: decoding response: %w", err)
+	}
+	return nil
+}
+
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Error is a problem response returned by the API
+type Error struct {
+	Status        int            `json:"status"`
+	Code          string         `json:"code"`
+	Title         string         `json:"title"`
+	Detail        string         `json:"detail"`
+	Instance      string         `json:"instance"`
+	InvalidParams []InvalidParam `json:"invalid_params"`
+}
+
+// InvalidParam names a field that failed validation
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("api error %d: %s", e.Status, e.Detail)
+	}
+	return fmt.Sprintf("api error %d (%s): %s", e.Status, e.Code, e.Detail)
+}
+
+// IsNotFound reports whether err is a 404 from the API
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
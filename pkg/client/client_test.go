@@ -0,0 +1,286 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingServer answers each request with the next of its handlers,
+// repeating the last one, and keeps the requests it was sent
+type recordingServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newRecordingServer(t *testing.T, handlers ...http.HandlerFunc) *recordingServer {
+	s := &recordingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		n := len(s.requests)
+		s.requests = append(s.requests, r)
+		s.mu.Unlock()
+
+		handlers[min(n, len(handlers)-1)](w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *recordingServer) calls() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// newTestClient returns a client for s that retries without waiting
+func newTestClient(s *recordingServer, opts ...Option) *Client {
+	c := New(s.URL, opts...)
+	c.MinBackoff = time.Millisecond
+	c.MaxBackoff = time.Millisecond
+	return c
+}
+
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+const productBody = `{"product": {"id": 7, "name": "Lamp"}}`
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		calls    int
+		status   int
+	}{
+		{name: "success", statuses: []int{200}, calls: 1},
+		{name: "server error then success", statuses: []int{500, 503, 200}, calls: 3},
+		{name: "rate limited then success", statuses: []int{429, 200}, calls: 2},
+		{name: "gives up", statuses: []int{503}, calls: 3, status: 503},
+		{name: "client error", statuses: []int{404}, calls: 1, status: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var handlers []http.HandlerFunc
+			for _, status := range tt.statuses {
+				body := `{"status": ` + strconv.Itoa(status) + `, "code": "x"}`
+				if status == 200 {
+					body = productBody
+				}
+				handlers = append(handlers, respond(status, body))
+			}
+			s := newRecordingServer(t, handlers...)
+
+			_, err := newTestClient(s, WithRetries(2)).Products.Get(context.Background(), 7)
+
+			if got := len(s.calls()); got != tt.calls {
+				t.Errorf("got %d calls, want %d", got, tt.calls)
+			}
+			var apiErr *Error
+			switch {
+			case tt.status == 0 && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.status != 0 && (!errors.As(err, &apiErr) || apiErr.Status != tt.status):
+				t.Errorf("got %v, want an api error %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	s := newRecordingServer(t,
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", "0")
+			respond(http.StatusTooManyRequests, `{"code": "rate_limit_exceeded"}`)(w, r)
+		},
+		respond(http.StatusOK, productBody),
+	)
+
+	// the backoff alone would outlast the context; Retry-After must win
+	c := New(s.URL)
+	c.MinBackoff = time.Hour
+	c.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	product, err := c.Products.Get(ctx, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if product.ID != 7 || len(s.calls()) != 2 {
+		t.Errorf("got product %d after %d calls, want 7 after 2", product.ID, len(s.calls()))
+	}
+}
+
+func TestBackoffHonoursContext(t *testing.T) {
+	s := newRecordingServer(t, respond(http.StatusServiceUnavailable, `{"code": "server_error"}`))
+
+	c := New(s.URL)
+	c.MinBackoff = time.Hour
+	c.MaxBackoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Products.Get(ctx, 7)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context's deadline", err)
+	}
+}
+
+func TestPostRetries(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		call  func(c *Client) error
+		calls int
+		keyed bool
+	}{
+		{
+			name:  "keyed endpoint with a token",
+			token: "TOKEN",
+			call: func(c *Client) error {
+				return c.Products.Create(context.Background(), &Product{Name: "Lamp"})
+			},
+			calls: 2,
+			keyed: true,
+		},
+		{
+			name: "keyed endpoint without a token",
+			call: func(c *Client) error {
+				return c.Reviews.Create(context.Background(), 7, &Review{Content: "Bright"})
+			},
+			calls: 1,
+		},
+		{
+			name:  "endpoint without keys",
+			token: "TOKEN",
+			call: func(c *Client) error {
+				_, err := c.Reviews.Report(context.Background(), 7, 1, "spam", "")
+				return err
+			},
+			calls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRecordingServer(t,
+				respond(http.StatusInternalServerError, `{"code": "server_error"}`),
+				respond(http.StatusCreated, `{"product": {"id": 7}, "review": {"id": 1}}`),
+			)
+			var opts []Option
+			if tt.token != "" {
+				opts = append(opts, WithToken(tt.token))
+			}
+
+			tt.call(newTestClient(s, opts...))
+
+			calls := s.calls()
+			if len(calls) != tt.calls {
+				t.Fatalf("got %d calls, want %d", len(calls), tt.calls)
+			}
+			key := calls[0].Header.Get("Idempotency-Key")
+			if (key != "") != tt.keyed {
+				t.Errorf("got Idempotency-Key %q, want one: %v", key, tt.keyed)
+			}
+			for _, call := range calls[1:] {
+				if got := call.Header.Get("Idempotency-Key"); got != key {
+					t.Errorf("a retry sent key %q, want the original %q", got, key)
+				}
+			}
+		})
+	}
+}
+
+func TestIterator(t *testing.T) {
+	tests := []struct {
+		name    string
+		total   int
+		limit   int
+		offset  int
+		offsets []string
+	}{
+		{name: "partial last page", total: 5, limit: 2, offsets: []string{"0", "2", "4"}},
+		{name: "full last page", total: 4, limit: 2, offsets: []string{"0", "2", "4"}},
+		{name: "empty", total: 0, limit: 2, offsets: []string{"0"}},
+		{name: "starting offset", total: 5, limit: 2, offset: 3, offsets: []string{"3", "5"}},
+		{name: "default page size", total: 3, offsets: []string{"0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRecordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+				offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+				var products []*Product
+				for id := offset + 1; id <= tt.total && len(products) < limit; id++ {
+					products = append(products, &Product{ID: int64(id)})
+				}
+				// the list endpoints answer 404 when nothing matches
+				if len(products) == 0 {
+					respond(http.StatusNotFound, `{"code": "not_found"}`)(w, r)
+					return
+				}
+				body, _ := json.Marshal(map[string]any{"products": products})
+				respond(http.StatusOK, string(body))(w, r)
+			})
+
+			products, err := newTestClient(s).Products.List(context.Background(), Filters{Limit: tt.limit, Offset: tt.offset}).All()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := tt.total - tt.offset
+			if len(products) != want {
+				t.Errorf("got %d products, want %d", len(products), want)
+			}
+			for i, product := range products {
+				if product.ID != int64(tt.offset+i+1) {
+					t.Errorf("product %d has ID %d, want %d", i, product.ID, tt.offset+i+1)
+				}
+			}
+
+			var offsets []string
+			for _, call := range s.calls() {
+				offsets = append(offsets, call.URL.Query().Get("offset"))
+			}
+			if len(offsets) != len(tt.offsets) {
+				t.Fatalf("fetched offsets %v, want %v", offsets, tt.offsets)
+			}
+			for i := range offsets {
+				if offsets[i] != tt.offsets[i] {
+					t.Errorf("fetched offsets %v, want %v", offsets, tt.offsets)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestIteratorError(t *testing.T) {
+	s := newRecordingServer(t, respond(http.StatusUnprocessableEntity, `{"status": 422, "code": "failed_validation"}`))
+
+	it := newTestClient(s).Products.List(context.Background(), Filters{Sort: "bogus"})
+	if it.Next() {
+		t.Fatal("Next reported an item from a failed request")
+	}
+	var apiErr *Error
+	if !errors.As(it.Err(), &apiErr) || apiErr.Code != "failed_validation" {
+		t.Errorf("got %v, want the failed_validation error", it.Err())
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+)
+
+// defaultPageSize is used when Filters.Limit is not set
+const defaultPageSize = 100
+
+// Iterator walks every page of a list endpoint, fetching the next page
+// only when the current one is used up.
+type Iterator[T any] struct {
+	ctx    context.Context
+	fetch  func(ctx context.Context, limit int, offset int) ([]T, error)
+	limit  int
+	offset int
+
+	page []T
+	item T
+	done bool
+	err  error
+}
+
+func newIterator[T any](ctx context.Context, filters Filters, fetch func(context.Context, int, int) ([]T, error)) *Iterator[T] {
+	limit := filters.Limit
+	if limit <= 0 || limit > defaultPageSize {
+		limit = defaultPageSize
+	}
+	return &Iterator[T]{
+		ctx:    ctx,
+		fetch:  fetch,
+		limit:  limit,
+		offset: filters.Offset,
+	}
+}
+
+// Next advances to the next item, reporting false at the end of the list
+// or on error
+func (it *Iterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if len(it.page) == 0 {
+		if it.done {
+			return false
+		}
+
+		page, err := it.fetch(it.ctx, it.limit, it.offset)
+		if IsNotFound(err) {
+			// the list endpoints answer 404 once there is nothing left
+			page, err = nil, nil
+		}
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.offset += len(page)
+		if len(page) < it.limit {
+			it.done = true
+		}
+		if len(page) == 0 {
+			return false
+		}
+		it.page = page
+	}
+
+	it.item = it.page[0]
+	it.page = it.page[1:]
+	return true
+}
+
+// Item returns the current item
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error that stopped iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All drains the iterator into a slice
+func (it *Iterator[T]) All() ([]T, error) {
+	var items []T
+	for it.Next() {
+		items = append(items, it.Item())
+	}
+	return items, it.Err()
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ProductsService groups the /v1/products endpoints
+type ProductsService struct {
+	client *Client
+}
+
+// ProductUpdate changes the fields that are set and leaves nil fields as they are
+type ProductUpdate struct {
+	Name         *string `json:"name,omitempty"`
+	Description  *string `json:"description,omitempty"`
+	Category     *string `json:"category,omitempty"`
+	ImageURL     *string `json:"image_url,omitempty"`
+	Status       *string `json:"status,omitempty"`
+	Price        *int64  `json:"price,omitempty"`
+	Currency     *string `json:"currency,omitempty"`
+	Availability *string `json:"availability,omitempty"`
+}
+
+// Get fetches a single product
+func (s *ProductsService) Get(ctx context.Context, id int64) (*Product, error) {
+	var out struct {
+		Product *Product `json:"product"`
+	}
+	err := s.client.do(ctx, request{
+		method:     http.MethodGet,
+		path:       fmt.Sprintf("/v1/products/%d", id),
+		idempotent: true,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Product, nil
+}
+
+// List iterates over every product matching filters, page by page
+func (s *ProductsService) List(ctx context.Context, filters Filters) *Iterator[*Product] {
+	return newIterator(ctx, filters, func(ctx context.Context, limit int, offset int) ([]*Product, error) {
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		if filters.Sort != "" {
+			query.Set("sort", filters.Sort)
+		}
+		if filters.Name != "" {
+			query.Set("name", filters.Name)
+		}
+		if filters.Category != "" {
+			query.Set("category", filters.Category)
+		}
+		if filters.Status != "" {
+			query.Set("status", filters.Status)
+		}
+		if filters.MinPrice != nil {
+			query.Set("min_price", strconv.FormatInt(*filters.MinPrice, 10))
+		}
+		if filters.MaxPrice != nil {
+			query.Set("max_price", strconv.FormatInt(*filters.MaxPrice, 10))
+		}
+		if filters.InStock != nil {
+			query.Set("in_stock", strconv.FormatBool(*filters.InStock))
+		}
+
+		var out struct {
+			Products []*Product `json:"products"`
+		}
+		err := s.client.do(ctx, request{
+			method:     http.MethodGet,
+			path:       "/v1/products",
+			query:      query,
+			idempotent: true,
+		}, &out)
+		return out.Products, err
+	})
+}
+
+// Create adds a product and fills in the fields the server assigns. It
+// needs a token with the products:edit permission.
+func (s *ProductsService) Create(ctx context.Context, product *Product) error {
+	body := map[string]any{
+		"name":         product.Name,
+		"description":  product.Description,
+		"category":     product.Category,
+		"image_url":    product.ImageURL,
+		"status":       product.Status,
+		"price":        product.Price,
+		"currency":     product.Currency,
+		"availability": product.Availability,
+	}
+
+	var out struct {
+		Product *Product `json:"product"`
+	}
+	err := s.client.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/v1/products",
+		body:   body,
+		keyed:  true,
+	}, &out)
+	if err != nil {
+		return err
+	}
+	*product = *out.Product
+	return nil
+}
+
+// Update applies the set fields of update to a product. Like Create, it
+// needs a products:edit token.
+func (s *ProductsService) Update(ctx context.Context, id int64, update ProductUpdate) (*Product, error) {
+	var out struct {
+		Product *Product `json:"product"`
+	}
+	err := s.client.do(ctx, request{
+		method: http.MethodPatch,
+		path:   fmt.Sprintf("/v1/products/%d", id),
+		body:   update,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Product, nil
+}
+
+// Delete removes a product. It needs a products:edit token; restoring the
+// product afterwards needs catalog:restore.
+func (s *ProductsService) Delete(ctx context.Context, id int64) error {
+	return s.client.do(ctx, request{
+		method:     http.MethodDelete,
+		path:       fmt.Sprintf("/v1/products/%d", id),
+		idempotent: true,
+	}, nil)
+}
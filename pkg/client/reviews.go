@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ReviewsService groups the review endpoints
+type ReviewsService struct {
+	client *Client
+}
+
+// ReviewUpdate changes the fields that are set and leaves nil fields as they are
+type ReviewUpdate struct {
+	Content   *string `json:"content,omitempty"`
+	Author    *string `json:"author,omitempty"`
+	Rating    *int    `json:"rating,omitempty"`
+	VariantID *int64  `json:"variant_id,omitempty"`
+}
+
+func reviewPath(productID int64, reviewID int64) string {
+	return fmt.Sprintf("/v1/products/%d/reviews/%d", productID, reviewID)
+}
+
+// Get fetches a single review of a product
+func (s *ReviewsService) Get(ctx context.Context, productID int64, reviewID int64) (*Review, error) {
+	var out struct {
+		Review *Review `json:"review"`
+	}
+	err := s.client.do(ctx, request{
+		method:     http.MethodGet,
+		path:       reviewPath(productID, reviewID),
+		idempotent: true,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Review, nil
+}
+
+// List iterates over every review matching filters, page by page. Set
+// filters.ProductID to restrict it to one product.
+func (s *ReviewsService) List(ctx context.Context, filters Filters) *Iterator[*Review] {
+	return newIterator(ctx, filters, func(ctx context.Context, limit int, offset int) ([]*Review, error) {
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		if filters.Sort != "" {
+			query.Set("sort", filters.Sort)
+		}
+		if filters.ProductID != 0 {
+			query.Set("product_id", strconv.FormatInt(filters.ProductID, 10))
+		}
+		if filters.VariantID != 0 {
+			query.Set("variant_id", strconv.FormatInt(filters.VariantID, 10))
+		}
+
+		var out struct {
+			Reviews []*Review `json:"reviews"`
+		}
+		err := s.client.do(ctx, request{
+			method:     http.MethodGet,
+			path:       "/v1/reviews",
+			query:      query,
+			idempotent: true,
+		}, &out)
+		return out.Reviews, err
+	})
+}
+
+// Create adds a review to a product and fills in the fields the server assigns
+func (s *ReviewsService) Create(ctx context.Context, productID int64, review *Review) error {
+	body := map[string]any{
+		"content":    review.Content,
+		"author":     review.Author,
+		"rating":     review.Rating,
+		"variant_id": review.VariantID,
+	}
+
+	var out struct {
+		Review *Review `json:"review"`
+	}
+	err := s.client.do(ctx, request{
+		method: http.MethodPost,
+		path:   fmt.Sprintf("/v1/products/%d/reviews", productID),
+		body:   body,
+		keyed:  true,
+	}, &out)
+	if err != nil {
+		return err
+	}
+	*review = *out.Review
+	return nil
+}
+
+// PutMine creates the signed-in user's review of a product, or replaces it
+// if they have already written one, and fills in the fields the server
+// assigns. The client must have been created WithToken.
+func (s *ReviewsService) PutMine(ctx context.Context, productID int64, review *Review) error {
+	body := map[string]any{
+		"content":    review.Content,
+		"author":     review.Author,
+		"rating":     review.Rating,
+		"variant_id": review.VariantID,
+	}
+
+	var out struct {
+		Review *Review `json:"review"`
+	}
+	err := s.client.do(ctx, request{
+		method:     http.MethodPut,
+		path:       fmt.Sprintf("/v1/products/%d/reviews/mine", productID),
+		body:       body,
+		idempotent: true,
+	}, &out)
+	if err != nil {
+		return err
+	}
+	*review = *out.Review
+	return nil
+}
+
+// Update applies the set fields of update to a review. Only the review's
+// author or a moderator can update it, so the client must have been
+// created WithToken.
+func (s *ReviewsService) Update(ctx context.Context, productID int64, reviewID int64, update ReviewUpdate) (*Review, error) {
+	var out struct {
+		Review *Review `json:"review"`
+	}
+	err := s.client.do(ctx, request{
+		method: http.MethodPatch,
+		path:   reviewPath(productID, reviewID),
+		body:   update,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Review, nil
+}
+
+// Delete removes a review. Like Update, it needs a token for the review's
+// author or a moderator.
+func (s *ReviewsService) Delete(ctx context.Context, productID int64, reviewID int64) error {
+	return s.client.do(ctx, request{
+		method:     http.MethodDelete,
+		path:       reviewPath(productID, reviewID),
+		idempotent: true,
+	}, nil)
+}
+
+// MarkHelpful records the client's helpful vote and returns the updated
+// review. It needs a token; each user's vote counts once, so voting again
+// is harmless.
+func (s *ReviewsService) MarkHelpful(ctx context.Context, productID int64, reviewID int64) (*Review, error) {
+	var out struct {
+		Review *Review `json:"review"`
+	}
+	err := s.client.do(ctx, request{
+		method:     http.MethodPost,
+		path:       reviewPath(productID, reviewID) + "/helpful",
+		idempotent: true,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Review, nil
+}
+
+// Report flags a review as abusive. reason is one of spam, offensive,
+// fake, off_topic or other; details are required for other. The client
+// must have been created WithToken.
+func (s *ReviewsService) Report(ctx context.Context, productID int64, reviewID int64, reason string, details string) (*Report, error) {
+	var out struct {
+		Report *Report `json:"report"`
+	}
+	err := s.client.do(ctx, request{
+		method: http.MethodPost,
+		path:   reviewPath(productID, reviewID) + "/reports",
+		body: map[string]string{
+			"reason":  reason,
+			"details": details,
+		},
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	return out.Report, nil
+}